@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/config/properties"
+	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/deadletter"
+	"github.com/PesquisAi/pesquisai-rabbitmq-lib/rabbitmq"
+	"github.com/joho/godotenv"
+	"os"
+	"sort"
+	"text/tabwriter"
+)
+
+func list(queue *deadletter.Queue, limit int) error {
+	letters, err := queue.List(limit)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tCODE\tFAILED AT\tREASON")
+	for _, letter := range letters {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", letter.Id, letter.Code, letter.FailedAt, letter.Reason)
+	}
+	return w.Flush()
+}
+
+func inspect(queue *deadletter.Queue, id string) error {
+	letters, err := queue.List(0)
+	if err != nil {
+		return err
+	}
+
+	for _, letter := range letters {
+		if letter.Id != id {
+			continue
+		}
+
+		keys := make([]string, 0, len(letter.Headers))
+		for key := range letter.Headers {
+			if key != deadletter.HeaderPayload {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+
+		fmt.Printf("id: %s\n", letter.Id)
+		for _, key := range keys {
+			fmt.Printf("%s: %v\n", key, letter.Headers[key])
+		}
+
+		var payload bytes.Buffer
+		if json.Indent(&payload, letter.Payload, "", "  ") != nil {
+			payload.Reset()
+			payload.Write(letter.Payload)
+		}
+		fmt.Printf("payload:\n%s\n", payload.String())
+		return nil
+	}
+	return fmt.Errorf("dead letter %s not found", id)
+}
+
+func reinject(queue *deadletter.Queue, id string) error {
+	found, err := queue.Reinject(context.Background(), id)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return fmt.Errorf("dead letter %s not found", id)
+	}
+
+	fmt.Printf("dead letter %s reinjected\n", id)
+	return nil
+}
+
+// dead-letter lists, inspects and reinjects the messages the orchestrator
+// consumers dead-lettered, e.g.
+//
+//	go run ./cmd/dead-letter -queue ai-orchestrator-callback list
+//	go run ./cmd/dead-letter -queue ai-orchestrator-callback -id <id> inspect
+//	go run ./cmd/dead-letter -queue ai-orchestrator-callback -id <id> reinject
+func main() {
+	var (
+		env   = flag.String("env", ".env", "optional env file with the queue connection")
+		queue = flag.String("queue", properties.QueueNameAiOrchestratorCallback, "consumer queue whose dead letters are handled (ai-orchestrator, ai-orchestrator-callback)")
+		id    = flag.String("id", "", "id of the dead letter to inspect or reinject")
+		limit = flag.Int("limit", 50, "maximum of dead letters listed, 0 for all of them")
+	)
+	flag.Parse()
+
+	command := flag.Arg(0)
+	if command != "list" && *id == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	_ = godotenv.Load(*env)
+
+	connection := &rabbitmq.Connection{}
+	err := connection.Connect(
+		properties.QueueConnectionUser(),
+		properties.QueueConnectionPassword(),
+		properties.QueueConnectionHost(),
+		properties.QueueConnectionPort(),
+	)
+	if err != nil {
+		panic(err)
+	}
+	defer connection.Disconnect()
+
+	deadLetters := deadletter.NewQueue(connection, *queue)
+	if err = deadLetters.Connect(); err != nil {
+		panic(err)
+	}
+
+	switch command {
+	case "list":
+		err = list(deadLetters, *limit)
+	case "inspect":
+		err = inspect(deadLetters, *id)
+	case "reinject":
+		err = reinject(deadLetters, *id)
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command '%s', expected list, inspect or reinject\n", command)
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
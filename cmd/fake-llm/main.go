@@ -0,0 +1,239 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/config/properties"
+	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/builder"
+	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/llm"
+	"github.com/PesquisAi/pesquisai-rabbitmq-lib/rabbitmq"
+	"github.com/joho/godotenv"
+	amqp "github.com/rabbitmq/amqp091-go"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// invalidResponse is the answer given to the attempts that must fail, which no
+// stage accepts.
+const invalidResponse = "this is not a JSON answer"
+
+type geminiMessage struct {
+	RequestId   *string        `json:"request_id"`
+	ResearchId  *string        `json:"research_id,omitempty"`
+	Question    *string        `json:"question"`
+	OutputQueue *string        `json:"output_queue"`
+	Forward     map[string]any `json:"forward"`
+}
+
+type callbackMessage struct {
+	RequestId  *string        `json:"request_id"`
+	ResearchId *string        `json:"research_id,omitempty"`
+	Response   string         `json:"response"`
+	Forward    map[string]any `json:"forward"`
+}
+
+// worker answers the prompts of the gemini queue with canned responses,
+// echoing their forward to the output queue like the real worker does.
+type worker struct {
+	connection *rabbitmq.Connection
+	responses  map[string]string
+	invalid    map[string]int
+
+	mu     sync.Mutex
+	queues map[string]*rabbitmq.Queue
+}
+
+func (w *worker) queue(name string) (*rabbitmq.Queue, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if queue, ok := w.queues[name]; ok {
+		return queue, nil
+	}
+
+	queue := rabbitmq.NewQueue(w.connection, name, rabbitmq.ContentTypeJson, properties.CreateQueueIfNX(), false, false)
+	if err := queue.Connect(); err != nil {
+		return nil, err
+	}
+	w.queues[name] = queue
+	return queue, nil
+}
+
+// respond is the answer to the attempt of an action: invalid for its first
+// attempts when asked so, canned otherwise. The sentences not overridden are
+// as many as the question asks for.
+func (w *worker) respond(action, question string, receiveCount int) (string, error) {
+	if receiveCount < w.invalid[action] {
+		return invalidResponse, nil
+	}
+
+	response, ok := w.responses[action]
+	if !ok {
+		response, ok = llm.StubResponse(action, question)
+	}
+	if !ok {
+		return "", fmt.Errorf("no canned response for action '%s'", action)
+	}
+	return response, nil
+}
+
+func (w *worker) handle(delivery amqp.Delivery) error {
+	ctx := context.Background()
+
+	payload, err := builder.Unwrap(delivery.Body)
+	if err != nil {
+		return err
+	}
+
+	var message geminiMessage
+	if err = json.Unmarshal(payload, &message); err != nil {
+		return err
+	}
+	if message.Question == nil || message.OutputQueue == nil || message.Forward == nil {
+		return fmt.Errorf(`"question", "output_queue" and "forward" are required`)
+	}
+
+	action, _ := message.Forward["action"].(string)
+	receiveCount, _ := message.Forward["receive_count"].(float64)
+
+	response, err := w.respond(action, *message.Question, int(receiveCount))
+	if err != nil {
+		return err
+	}
+
+	b, err := json.Marshal(callbackMessage{
+		RequestId:  message.RequestId,
+		ResearchId: message.ResearchId,
+		Response:   response,
+		Forward:    message.Forward,
+	})
+	if err != nil {
+		return err
+	}
+
+	queue, err := w.queue(*message.OutputQueue)
+	if err != nil {
+		return err
+	}
+
+	slog.InfoContext(ctx, "fakeLlm.handle",
+		slog.String("action", action),
+		slog.Int("receiveCount", int(receiveCount)),
+		slog.Bool("invalid", response == invalidResponse),
+		slog.String("outputQueue", *message.OutputQueue))
+	return queue.Publish(ctx, b)
+}
+
+// parseInvalid reads a list such as "summarize=2,overall=1": how many
+// attempts of each action get an invalid answer before the canned one.
+func parseInvalid(value string) (map[string]int, error) {
+	invalid := map[string]int{}
+	for _, entry := range strings.Split(value, ",") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		action, count, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid entry '%s', expected <action>=<attempts>", entry)
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(count))
+		if err != nil {
+			return nil, fmt.Errorf("invalid entry '%s': %w", entry, err)
+		}
+		invalid[strings.TrimSpace(action)] = n
+	}
+	return invalid, nil
+}
+
+// loadResponses are the canned responses of the stub client, overridden by
+// the actions of the given JSON file, e.g. {"summarize": "{\"summary\":\"...\"}"}.
+func loadResponses(path string) (map[string]string, error) {
+	responses := map[string]string{}
+	for action, response := range llm.StubResponses {
+		responses[action] = response
+	}
+	if path == "" {
+		return responses, nil
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var overrides map[string]string
+	if err = json.Unmarshal(b, &overrides); err != nil {
+		return nil, err
+	}
+	for action, response := range overrides {
+		responses[action] = response
+	}
+	return responses, nil
+}
+
+// fake-llm stands in for the gemini worker on local runs, e.g.
+//
+//	go run ./cmd/fake-llm
+//	go run ./cmd/fake-llm -invalid summarize=2,sentences=1
+//	go run ./cmd/fake-llm -responses ./responses.json
+func main() {
+	var (
+		env       = flag.String("env", ".env", "optional env file with the queue connection")
+		invalid   = flag.String("invalid", "", "attempts answered with an invalid response, by action (e.g. summarize=2,overall=1)")
+		responses = flag.String("responses", "", "optional JSON file of canned responses by action, overriding the default ones")
+	)
+	flag.Parse()
+
+	_ = godotenv.Load(*env)
+
+	invalidAttempts, err := parseInvalid(*invalid)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	cannedResponses, err := loadResponses(*responses)
+	if err != nil {
+		panic(err)
+	}
+
+	connection := &rabbitmq.Connection{}
+	err = connection.Connect(
+		properties.QueueConnectionUser(),
+		properties.QueueConnectionPassword(),
+		properties.QueueConnectionHost(),
+		properties.QueueConnectionPort(),
+	)
+	if err != nil {
+		panic(err)
+	}
+	defer connection.Disconnect()
+
+	queue := rabbitmq.NewQueue(connection,
+		properties.QueueNameGemini,
+		rabbitmq.ContentTypeJson,
+		properties.CreateQueueIfNX(),
+		false, false)
+	if err = queue.Connect(); err != nil {
+		panic(err)
+	}
+
+	w := &worker{
+		connection: connection,
+		responses:  cannedResponses,
+		invalid:    invalidAttempts,
+		queues:     map[string]*rabbitmq.Queue{},
+	}
+
+	slog.Info("fakeLlm.main",
+		slog.String("details", fmt.Sprintf("answering the %s queue", properties.QueueNameGemini)))
+	if err = queue.Consume(context.Background(), w.handle); err != nil {
+		panic(err)
+	}
+}
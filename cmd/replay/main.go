@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/config/properties"
+	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/delivery/dtos"
+	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/builder"
+	enumactions "github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/enums/actions"
+	"github.com/PesquisAi/pesquisai-rabbitmq-lib/rabbitmq"
+	"github.com/joho/godotenv"
+	"os"
+)
+
+// replay asks the orchestrator to re-run one stage of a request, e.g.
+//
+//	go run ./cmd/replay -request <request-id> -from sentences
+//	go run ./cmd/replay -request <request-id> -research <research-id> -from summarize
+func main() {
+	var (
+		env        = flag.String("env", ".env", "optional env file with the queue connection")
+		requestId  = flag.String("request", "", "id of the request to replay")
+		researchId = flag.String("research", "", "id of the research to replay, for research stages")
+		from       = flag.String("from", "", "stage to re-run (language, sentences, worth-checking, worth-summarize, summarize, overall)")
+	)
+	flag.Parse()
+
+	if *requestId == "" || *from == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	_ = godotenv.Load(*env)
+
+	connection := &rabbitmq.Connection{}
+	err := connection.Connect(
+		properties.QueueConnectionUser(),
+		properties.QueueConnectionPassword(),
+		properties.QueueConnectionHost(),
+		properties.QueueConnectionPort(),
+	)
+	if err != nil {
+		panic(err)
+	}
+	defer connection.Disconnect()
+
+	queue := rabbitmq.NewQueue(connection,
+		properties.QueueNameAiOrchestrator,
+		rabbitmq.ContentTypeJson,
+		properties.CreateQueueIfNX(),
+		false, false)
+	if err = queue.Connect(); err != nil {
+		panic(err)
+	}
+
+	action := enumactions.Replay
+	request := dtos.AiOrchestratorRequest{
+		RequestId:    requestId,
+		Action:       &action,
+		ReplayAction: from,
+	}
+	if *researchId != "" {
+		request.ResearchId = researchId
+	}
+
+	b, err := builder.BuildQueueOrchestratorMessage(request)
+	if err != nil {
+		panic(err)
+	}
+
+	if err = queue.Publish(context.Background(), b); err != nil {
+		panic(err)
+	}
+
+	fmt.Printf("replay of '%s' requested for request %s\n", *from, *requestId)
+}
@@ -11,12 +11,16 @@ import (
 func main() {
 	deps := injector.NewDependencies()
 
+	if err := deps.Pipeline.Validate(); err != nil {
+		panic(err)
+	}
+
 	if err := connections.Connect(deps); err != nil {
 		panic(err)
 	}
 
 	var wg sync.WaitGroup
-	wg.Add(3)
+	wg.Add(5)
 
 	go func() {
 		if err := deps.ConsumerAiOrchestratorQueue.Consume(context.TODO(), deps.Controller.AiOrchestratorHandler); err != nil {
@@ -32,4 +36,26 @@ func main() {
 		wg.Done()
 	}()
 
+	go func() {
+		if err := deps.Sweeper.Run(context.TODO()); err != nil {
+			slog.Error("Error during sweeper routine", slog.String("error", err.Error()))
+		}
+		wg.Done()
+	}()
+
+	go func() {
+		if err := deps.Relay.Run(context.TODO()); err != nil {
+			slog.Error("Error during outbox relay routine", slog.String("error", err.Error()))
+		}
+		wg.Done()
+	}()
+
+	go func() {
+		if err := deps.OpenAIRelay.Run(context.TODO()); err != nil {
+			slog.Error("Error during openai relay routine", slog.String("error", err.Error()))
+		}
+		wg.Done()
+	}()
+
+	wg.Wait()
 }
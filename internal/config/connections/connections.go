@@ -4,6 +4,8 @@ import (
 	"context"
 	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/config/injector"
 	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/config/properties"
+	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/dedup"
+	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/outbox"
 	"github.com/PesquisAi/pesquisai-database-lib/sql/connection"
 	"gorm.io/gorm"
 	"gorm.io/gorm/schema"
@@ -39,6 +41,41 @@ func Connect(deps *injector.Dependencies) error {
 		properties.DatabaseNoSqlName,
 		properties.DatabaseOrchestratorCollectionName)
 
+	deps.PromptRepository.Connect(
+		properties.DatabaseNoSqlName,
+		properties.DatabasePromptCollectionName)
+
+	deps.DeduplicationRepository.Connect(
+		properties.DatabaseNoSqlName,
+		properties.DatabaseDeliveryCollectionName)
+
+	err = deps.DeduplicationRepository.EnsureTTLIndex(context.Background(), "claimedAt", dedup.Retention)
+	if err != nil {
+		return err
+	}
+
+	deps.OutboxRepository.Connect(
+		properties.DatabaseNoSqlName,
+		properties.DatabaseOutboxCollectionName)
+
+	err = deps.OutboxRepository.EnsureTTLIndex(context.Background(), "sentAt", outbox.Retention)
+	if err != nil {
+		return err
+	}
+
+	deps.CacheRepository.Connect(
+		properties.DatabaseNoSqlName,
+		properties.DatabaseCacheCollectionName)
+
+	err = deps.CacheRepository.EnsureTTLIndex(context.Background(), "createdAt", properties.ResponseCacheTTL())
+	if err != nil {
+		return err
+	}
+
+	deps.UsageRepository.Connect(
+		properties.DatabaseNoSqlName,
+		properties.DatabaseUsageCollectionName)
+
 	err = deps.QueueConnection.Connect(
 		properties.QueueConnectionUser(),
 		properties.QueueConnectionPassword(),
@@ -84,6 +121,16 @@ func Connect(deps *injector.Dependencies) error {
 		return err
 	}
 
+	err = deps.DeadLetterAiOrchestrator.Connect()
+	if err != nil {
+		return err
+	}
+
+	err = deps.DeadLetterAiOrchestratorCallback.Connect()
+	if err != nil {
+		return err
+	}
+
 	return nil
 }
 
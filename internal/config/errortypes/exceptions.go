@@ -40,15 +40,12 @@ func NewServiceNotFoundException(messages ...string) *exceptions.Error {
 		}}
 }
 
-func NewInvalidAIResponseException(requestId, question, action string, receiveCount int, messages ...string) *exceptions.Error {
+// NewInvalidAIResponseException carries in its forward the prompt to be sent
+// again for the next attempt.
+func NewInvalidAIResponseException(forward map[string]any, messages ...string) *exceptions.Error {
 	return &exceptions.Error{
 		Messages: messages,
-		Forward: map[string]any{
-			"requestId":    requestId,
-			"question":     question,
-			"action":       action,
-			"receiveCount": receiveCount,
-		},
+		Forward:  forward,
 		ErrorType: exceptions.ErrorType{
 			Code:           InvalidAiResponseCode,
 			Type:           "Invalid AI response",
@@ -3,17 +3,40 @@ package injector
 import (
 	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/config/properties"
 	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/delivery/controllers"
+	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/acceptance"
+	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/cache"
+	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/chunking"
+	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/deadletter"
+	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/dedup"
+	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/dispatcher"
+	enumactions "github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/enums/actions"
+	enumproviders "github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/enums/providers"
 	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/factory"
+	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/guards"
+	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/injection"
 	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/interfaces"
+	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/language"
+	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/llm"
+	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/outbox"
+	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/pipeline"
+	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/prompts"
+	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/schemas"
 	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/services"
+	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/sweeper"
+	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/tracker"
+	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/usage"
 	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/usecases"
+	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/voting"
+	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/repositories"
 	nosql "github.com/PesquisAi/pesquisai-database-lib/nosql/connection"
 	nosqlrepositories "github.com/PesquisAi/pesquisai-database-lib/nosql/repositories"
 	sql "github.com/PesquisAi/pesquisai-database-lib/sql/connection"
 	sqlrepositories "github.com/PesquisAi/pesquisai-database-lib/sql/repositories"
 	"github.com/PesquisAi/pesquisai-rabbitmq-lib/rabbitmq"
 	"gorm.io/gorm"
+	"io/fs"
 	"net/http"
+	"text/template"
 )
 
 type Dependencies struct {
@@ -33,7 +56,41 @@ type Dependencies struct {
 	ConsumerAiOrchestratorQueue         interfaces.QueueConsumer
 	QueueAiOrchestrator                 interfaces.Queue
 	ConsumerAiOrchestratorCallbackQueue interfaces.QueueConsumer
+	QueueAiOrchestratorCallback         interfaces.Queue
+	DeadLetterAiOrchestrator            interfaces.DeadLetterQueue
+	DeadLetterAiOrchestratorCallback    interfaces.DeadLetterQueue
 	ServiceFactory                      *factory.ServiceFactory
+	Pipeline                            *pipeline.Pipeline
+	ResearchTracker                     interfaces.ResearchTracker
+	PromptRepository                    interfaces.PromptRepository
+	PromptDispatcher                    interfaces.PromptDispatcher
+	Sweeper                             interfaces.Sweeper
+	CancellationGuard                   interfaces.CancellationGuard
+	DeduplicationRepository             interfaces.DeduplicationRepository
+	DeduplicationStore                  interfaces.DeduplicationStore
+	OutboxRepository                    interfaces.OutboxRepository
+	CacheRepository                     interfaces.CacheRepository
+	UsageRepository                     interfaces.UsageRepository
+	UsageRecorder                       interfaces.UsageRecorder
+	ResponseCache                       interfaces.ResponseCache
+	OutboxQueueGemini                   interfaces.Queue
+	OutboxQueueGoogleSearch             interfaces.Queue
+	OutboxQueueStatusManager            interfaces.Queue
+	OutboxQueueWebScraper               interfaces.Queue
+	OutboxQueueAiOrchestrator           interfaces.Queue
+	OutboxQueueAiOrchestratorCallback   interfaces.Queue
+	OutboxQueueOpenAI                   interfaces.Queue
+	LLMClient                           interfaces.LLMClient
+	PromptRegistry                      interfaces.PromptRegistry
+	ResponseParser                      interfaces.ResponseParser
+	LocationAcceptancePolicy            *acceptance.Policy
+	LanguageAcceptancePolicy            *acceptance.Policy
+	ChunkSummarizer                     interfaces.ChunkSummarizer
+	WorthAccessingBallot                interfaces.Ballot
+	WorthSummarizeBallot                interfaces.Ballot
+	Transactor                          interfaces.Transactor
+	Relay                               interfaces.Relay
+	OpenAIRelay                         interfaces.Relay
 }
 
 func (d *Dependencies) Inject() *Dependencies {
@@ -46,7 +103,34 @@ func (d *Dependencies) Inject() *Dependencies {
 	}
 
 	if d.OrchestratorRepository == nil {
-		d.OrchestratorRepository = &nosqlrepositories.Repository{Connection: d.DatabaseNoSqlConnection}
+		d.OrchestratorRepository = repositories.NewRepository(&nosqlrepositories.Repository{Connection: d.DatabaseNoSqlConnection})
+	}
+
+	if d.PromptRepository == nil {
+		d.PromptRepository = repositories.NewRepository(&nosqlrepositories.Repository{Connection: d.DatabaseNoSqlConnection})
+	}
+
+	if d.DeduplicationRepository == nil {
+		d.DeduplicationRepository = repositories.NewRepository(&nosqlrepositories.Repository{Connection: d.DatabaseNoSqlConnection})
+	}
+
+	if d.OutboxRepository == nil {
+		d.OutboxRepository = repositories.NewRepository(&nosqlrepositories.Repository{Connection: d.DatabaseNoSqlConnection})
+	}
+
+	if d.CacheRepository == nil {
+		d.CacheRepository = repositories.NewRepository(&nosqlrepositories.Repository{Connection: d.DatabaseNoSqlConnection})
+	}
+
+	if d.UsageRepository == nil {
+		d.UsageRepository = repositories.NewRepository(&nosqlrepositories.Repository{Connection: d.DatabaseNoSqlConnection})
+	}
+
+	if d.Transactor == nil {
+		d.Transactor = &repositories.Transactor{
+			Connection: d.DatabaseNoSqlConnection,
+			Disabled:   !properties.DatabaseNoSqlTransactions(),
+		}
 	}
 
 	if d.Mux == nil {
@@ -104,32 +188,213 @@ func (d *Dependencies) Inject() *Dependencies {
 		d.QueueAiOrchestrator = queue
 	}
 
-	if d.ConsumerAiOrchestratorCallbackQueue == nil {
-		d.ConsumerAiOrchestratorCallbackQueue = rabbitmq.NewQueue(
+	if d.ConsumerAiOrchestratorCallbackQueue == nil || d.QueueAiOrchestratorCallback == nil {
+		queue := rabbitmq.NewQueue(
 			d.QueueConnection,
 			properties.QueueNameAiOrchestratorCallback,
 			rabbitmq.ContentTypeJson,
 			properties.CreateQueueIfNX(),
 			true, true)
+		d.ConsumerAiOrchestratorCallbackQueue = queue
+		d.QueueAiOrchestratorCallback = queue
+	}
+
+	if d.DeadLetterAiOrchestrator == nil {
+		d.DeadLetterAiOrchestrator = deadletter.NewQueue(d.QueueConnection, properties.QueueNameAiOrchestrator)
+	}
+
+	if d.DeadLetterAiOrchestratorCallback == nil {
+		d.DeadLetterAiOrchestratorCallback = deadletter.NewQueue(d.QueueConnection, properties.QueueNameAiOrchestratorCallback)
+	}
+
+	if d.OutboxQueueGemini == nil {
+		d.OutboxQueueGemini = outbox.NewQueue(d.OutboxRepository, properties.QueueNameGemini)
+	}
+
+	if d.OutboxQueueGoogleSearch == nil {
+		d.OutboxQueueGoogleSearch = outbox.NewQueue(d.OutboxRepository, properties.QueueNameGoogleSearch)
+	}
+
+	if d.OutboxQueueStatusManager == nil {
+		d.OutboxQueueStatusManager = outbox.NewQueue(d.OutboxRepository, properties.QueueNameStatusManager)
+	}
+
+	if d.OutboxQueueWebScraper == nil {
+		d.OutboxQueueWebScraper = outbox.NewQueue(d.OutboxRepository, properties.QueueNameWebScraper)
+	}
+
+	if d.OutboxQueueAiOrchestrator == nil {
+		d.OutboxQueueAiOrchestrator = outbox.NewQueue(d.OutboxRepository, properties.QueueNameAiOrchestrator)
+	}
+
+	if d.OutboxQueueAiOrchestratorCallback == nil {
+		d.OutboxQueueAiOrchestratorCallback = outbox.NewQueue(d.OutboxRepository, properties.QueueNameAiOrchestratorCallback)
+	}
+
+	if d.OutboxQueueOpenAI == nil {
+		d.OutboxQueueOpenAI = outbox.NewQueue(d.OutboxRepository, properties.OutboxNameOpenAI)
+	}
+
+	if d.Relay == nil {
+		d.Relay = outbox.NewRelay(d.OutboxRepository, map[string]interfaces.Queue{
+			properties.QueueNameGemini:                 d.QueueGemini,
+			properties.QueueNameGoogleSearch:           d.QueueGoogleSearch,
+			properties.QueueNameStatusManager:          d.QueueStatusManager,
+			properties.QueueNameWebScraper:             d.QueueWebScraper,
+			properties.QueueNameAiOrchestrator:         d.QueueAiOrchestrator,
+			properties.QueueNameAiOrchestratorCallback: d.QueueAiOrchestratorCallback,
+		}, properties.OutboxRelayInterval(), outbox.Lease, 0)
+	}
+
+	// The prompts of the openai provider are relayed apart: their chat
+	// completions call is slow, and they are held for twice its timeout so a
+	// call still running is not made again by another relay. Unlike a broker
+	// being down, a failing call may never succeed, so it is retried as many
+	// times as the consumers retry a delivery.
+	if d.OpenAIRelay == nil {
+		d.OpenAIRelay = outbox.NewRelay(d.OutboxRepository, map[string]interfaces.Queue{
+			properties.OutboxNameOpenAI: llm.NewOpenAIWorker(
+				&http.Client{Timeout: properties.LLMOpenAITimeout()},
+				properties.LLMOpenAIBaseURL(),
+				properties.LLMOpenAIApiKey(),
+				properties.LLMOpenAIModel(),
+				d.OutboxQueueAiOrchestratorCallback),
+		}, properties.OutboxRelayInterval(), 2*properties.LLMOpenAITimeout(), properties.QueueMaxRetries())
+	}
+
+	if d.Pipeline == nil {
+		d.Pipeline = &pipeline.Default
+	}
+
+	if d.ResearchTracker == nil {
+		d.ResearchTracker = tracker.NewResearchTracker(d.OutboxQueueAiOrchestrator, d.OutboxQueueStatusManager, d.OrchestratorRepository, d.RequestRepository, *d.Pipeline, properties.WebScraperTimeout())
+	}
+
+	if d.ResponseCache == nil {
+		d.ResponseCache = cache.NewResponseCache(d.CacheRepository, d.PromptRepository, !properties.ResponseCacheEnabled())
+	}
+
+	if d.LLMClient == nil {
+		d.LLMClient = llm.NewCachedClient(llm.NewRouter(map[string]interfaces.LLMClient{
+			enumproviders.Gemini: llm.NewGeminiClient(d.OutboxQueueGemini),
+			enumproviders.OpenAI: llm.NewOpenAIClient(d.OutboxQueueOpenAI),
+			enumproviders.Stub:   llm.NewStubClient(d.OutboxQueueAiOrchestratorCallback),
+		}, properties.LLMProvider, properties.EnsembleVoters), d.ResponseCache, d.OutboxQueueAiOrchestratorCallback)
+	}
+
+	if d.UsageRecorder == nil {
+		d.UsageRecorder = usage.NewRecorder(d.UsageRepository, d.OrchestratorRepository)
+	}
+
+	if d.PromptDispatcher == nil {
+		d.PromptDispatcher = dispatcher.NewPromptDispatcher(d.LLMClient, d.PromptRepository, d.UsageRecorder, *d.Pipeline)
+	}
+
+	if d.CancellationGuard == nil {
+		d.CancellationGuard = guards.NewCancellationGuard(d.OrchestratorRepository)
+	}
+
+	if d.DeduplicationStore == nil {
+		d.DeduplicationStore = dedup.NewDeduplicationStore(d.DeduplicationRepository)
+	}
+
+	if d.ResponseParser == nil {
+		definitions, err := fs.Sub(schemas.Definitions, "definitions")
+		if err != nil {
+			panic(err)
+		}
+
+		d.ResponseParser, err = schemas.NewResponseParser(definitions)
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	if d.PromptRegistry == nil {
+		templates, err := prompts.FS(properties.PromptTemplatesDir())
+		if err != nil {
+			panic(err)
+		}
+
+		d.PromptRegistry, err = prompts.NewRegistry(templates, properties.PromptTemplateVersion, prompts.Supported, template.FuncMap{
+			"schema":       d.ResponseParser.Schema,
+			"fence":        injection.Fence,
+			"languageName": language.Name,
+		})
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	if d.LocationAcceptancePolicy == nil {
+		policy, err := acceptance.NewPolicy(
+			properties.AcceptancePolicy(enumactions.Location),
+			properties.AcceptanceMinValid(enumactions.Location))
+		if err != nil {
+			panic(err)
+		}
+		d.LocationAcceptancePolicy = &policy
+	}
+
+	if d.LanguageAcceptancePolicy == nil {
+		policy, err := acceptance.NewPolicy(
+			properties.AcceptancePolicy(enumactions.Language),
+			properties.AcceptanceMinValid(enumactions.Language))
+		if err != nil {
+			panic(err)
+		}
+		d.LanguageAcceptancePolicy = &policy
+	}
+
+	if d.ChunkSummarizer == nil {
+		d.ChunkSummarizer = chunking.NewSummarizer(d.PromptDispatcher, d.PromptRegistry, d.OrchestratorRepository, d.OutboxQueueAiOrchestrator, properties.ChunkTokenBudget())
+	}
+
+	if d.WorthAccessingBallot == nil {
+		ensemble, err := voting.NewEnsemble(
+			properties.EnsembleVoters(enumactions.WorthAccessing),
+			properties.EnsembleRule(enumactions.WorthAccessing))
+		if err != nil {
+			panic(err)
+		}
+		d.WorthAccessingBallot = voting.NewBallot(d.OrchestratorRepository, enumactions.WorthAccessing, ensemble)
+	}
+
+	if d.WorthSummarizeBallot == nil {
+		ensemble, err := voting.NewEnsemble(
+			properties.EnsembleVoters(enumactions.WorthSummarize),
+			properties.EnsembleRule(enumactions.WorthSummarize))
+		if err != nil {
+			panic(err)
+		}
+		d.WorthSummarizeBallot = voting.NewBallot(d.OrchestratorRepository, enumactions.WorthSummarize, ensemble)
 	}
 
 	if d.ServiceFactory == nil {
 		d.ServiceFactory = &factory.ServiceFactory{
-			LocationService:       services.NewLocationService(d.QueueGemini, d.QueueAiOrchestrator, d.OrchestratorRepository, d.RequestRepository),
-			LanguageService:       services.NewLanguageService(d.QueueGemini, d.QueueAiOrchestrator, d.OrchestratorRepository, d.RequestRepository),
-			SentencesService:      services.NewSentenceService(d.QueueGemini, d.QueueGoogleSearch, d.OrchestratorRepository),
-			WorthAccessingService: services.NewWorthAccessingService(d.QueueGemini, d.QueueWebScraper, d.QueueStatusManager, d.OrchestratorRepository),
-			WorthSummarizeService: services.NewWorthSummarizeService(d.QueueGemini, d.QueueAiOrchestrator, d.QueueStatusManager, d.OrchestratorRepository),
-			SummarizeService:      services.NewSummarizeService(d.QueueGemini, d.QueueStatusManager, d.OrchestratorRepository),
+			LocationService:       services.NewLocationService(d.PromptDispatcher, d.PromptRegistry, d.ResponseParser, *d.LocationAcceptancePolicy, d.OrchestratorRepository, d.RequestRepository),
+			LanguageService:       services.NewLanguageService(d.PromptDispatcher, d.PromptRegistry, d.ResponseParser, *d.LanguageAcceptancePolicy, d.OrchestratorRepository, d.RequestRepository),
+			SentencesService:      services.NewSentenceService(d.PromptDispatcher, d.PromptRegistry, d.ResponseParser, d.OutboxQueueGoogleSearch, d.OrchestratorRepository),
+			WorthAccessingService: services.NewWorthAccessingService(d.PromptDispatcher, d.PromptRegistry, d.ResponseParser, d.OutboxQueueWebScraper, d.OutboxQueueStatusManager, d.OrchestratorRepository, d.ResearchTracker, d.WorthAccessingBallot, properties.RelevanceThreshold(enumactions.WorthAccessing)),
+			WorthSummarizeService: services.NewWorthSummarizeService(d.PromptDispatcher, d.PromptRegistry, d.ResponseParser, d.OutboxQueueStatusManager, d.OutboxQueueAiOrchestrator, d.OrchestratorRepository, d.ResearchTracker, d.ChunkSummarizer, d.WorthSummarizeBallot, properties.RelevanceThreshold(enumactions.WorthSummarize), *d.Pipeline),
+			SummarizeService:      services.NewSummarizeService(d.PromptDispatcher, d.PromptRegistry, d.ResponseParser, d.OutboxQueueStatusManager, d.OrchestratorRepository, d.ResearchTracker, d.ChunkSummarizer),
+			SummarizeChunkService: services.NewSummarizeChunkService(d.PromptRegistry, d.ResponseParser, d.OrchestratorRepository, d.ChunkSummarizer),
+			OverallService:        services.NewOverallService(d.PromptDispatcher, d.PromptRegistry, d.ResponseParser, d.OutboxQueueStatusManager, d.OrchestratorRepository),
+			CancelService:         services.NewCancelService(d.OutboxQueueStatusManager, d.OrchestratorRepository),
+			ReplayService:         services.NewReplayService(d.OutboxQueueAiOrchestrator, d.OrchestratorRepository, *d.Pipeline),
 		}
 	}
 
 	if d.UseCase == nil {
-		d.UseCase = usecases.NewUseCase(d.RequestRepository, d.ServiceFactory)
+		d.UseCase = usecases.NewUseCase(d.RequestRepository, d.OrchestratorRepository, d.ServiceFactory, d.OutboxQueueAiOrchestrator, d.PromptDispatcher, d.CancellationGuard, d.DeduplicationStore, d.Transactor, d.PromptRegistry, d.ResponseCache, d.UsageRecorder, *d.Pipeline)
+	}
+
+	if d.Sweeper == nil {
+		d.Sweeper = sweeper.NewSweeper(d.UseCase, d.PromptRepository, d.PromptDispatcher, d.OutboxQueueStatusManager, d.ResearchTracker, d.CancellationGuard, *d.Pipeline, properties.SweeperInterval())
 	}
 
 	if d.Controller == nil {
-		d.Controller = controllers.NewController(d.QueueGemini, d.UseCase)
+		d.Controller = controllers.NewController(d.PromptDispatcher, d.DeduplicationStore, d.UseCase, d.DeadLetterAiOrchestrator, d.DeadLetterAiOrchestratorCallback)
 	}
 	return d
 }
@@ -3,6 +3,8 @@ package properties
 import (
 	"os"
 	"strconv"
+	"strings"
+	"time"
 )
 
 const (
@@ -13,9 +15,28 @@ const (
 	QueueNameAiOrchestratorCallback = "ai-orchestrator-callback"
 	QueueNameStatusManager          = "status-manager"
 	QueueNameWebScraper             = "web-scraper"
+	// OutboxNameOpenAI is not a queue: its outbox messages are the prompts the
+	// openai worker sends to the chat completions endpoint.
+	OutboxNameOpenAI = "openai"
 
 	DatabaseNoSqlName                  = "pesquisai"
 	DatabaseOrchestratorCollectionName = "orchestrator"
+	DatabasePromptCollectionName       = "prompts"
+	DatabaseDeliveryCollectionName     = "deliveries"
+	DatabaseOutboxCollectionName       = "outbox"
+	DatabaseCacheCollectionName        = "responses"
+	DatabaseUsageCollectionName        = "usage"
+
+	defaultSweeperInterval     = 30 * time.Second
+	defaultWebScraperTimeout   = 15 * time.Minute
+	defaultOutboxRelayInterval = time.Second
+	defaultLLMProvider         = "gemini"
+	defaultLLMOpenAITimeout    = time.Minute
+	defaultAcceptancePolicy    = "strict"
+	defaultChunkTokenBudget    = 3000
+	defaultResponseCacheTTL    = 24 * time.Hour
+	defaultEnsembleRule        = "majority"
+	defaultRelevanceThreshold  = 50
 )
 
 func CreateQueueIfNX() bool {
@@ -62,6 +83,13 @@ func DatabaseNoSqlConnectionHost() string {
 	return os.Getenv("DATABASE_NO_SQL_CONNECTION_HOST")
 }
 
+// QueueMaxRetries is how many times the consumers retry a failed delivery
+// before it is dead-lettered.
+func QueueMaxRetries() int {
+	i, _ := strconv.Atoi(os.Getenv("QUEUE_MAX_RETRIES"))
+	return i
+}
+
 func GetMaxAiReceiveCount() int {
 	i, _ := strconv.Atoi(os.Getenv("MAX_AI_RECEIVE_COUNT"))
 	return i
@@ -70,3 +98,164 @@ func GetMaxAiReceiveCount() int {
 func DatabaseNoSqlConnectionPort() string {
 	return os.Getenv("DATABASE_NO_SQL_CONNECTION_PORT")
 }
+
+// DatabaseNoSqlTransactions is only meant to be disabled against a standalone
+// mongo, which does not support transactions.
+func DatabaseNoSqlTransactions() bool {
+	return os.Getenv("DATABASE_NO_SQL_TRANSACTIONS") != "false"
+}
+
+func SweeperInterval() time.Duration {
+	d, err := time.ParseDuration(os.Getenv("SWEEPER_INTERVAL"))
+	if err != nil || d <= 0 {
+		return defaultSweeperInterval
+	}
+	return d
+}
+
+// WebScraperTimeout is how long a research handed to the web-scraper may wait
+// for its content before it is finished as failed.
+func WebScraperTimeout() time.Duration {
+	d, err := time.ParseDuration(os.Getenv("WEB_SCRAPER_TIMEOUT"))
+	if err != nil || d <= 0 {
+		return defaultWebScraperTimeout
+	}
+	return d
+}
+
+func OutboxRelayInterval() time.Duration {
+	d, err := time.ParseDuration(os.Getenv("OUTBOX_RELAY_INTERVAL"))
+	if err != nil || d <= 0 {
+		return defaultOutboxRelayInterval
+	}
+	return d
+}
+
+// LLMProvider is the provider that answers the prompts of an action, set by
+// LLM_PROVIDER_<ACTION> (e.g. LLM_PROVIDER_WORTH_CHECKING) or LLM_PROVIDER for
+// every action.
+func LLMProvider(action string) string {
+	key := "LLM_PROVIDER_" + strings.ToUpper(strings.ReplaceAll(action, "-", "_"))
+	if provider := os.Getenv(key); provider != "" {
+		return provider
+	}
+	if provider := os.Getenv("LLM_PROVIDER"); provider != "" {
+		return provider
+	}
+	return defaultLLMProvider
+}
+
+// MessageEnvelope tells whether the messages published to the queue are
+// wrapped in the versioned envelope. MESSAGE_ENVELOPE_<QUEUE> overrides
+// MESSAGE_ENVELOPE, so the consumers of each queue are migrated on their own.
+func MessageEnvelope(queue string) bool {
+	key := "MESSAGE_ENVELOPE_" + strings.ToUpper(strings.ReplaceAll(queue, "-", "_"))
+	if enabled := os.Getenv(key); enabled != "" {
+		return enabled == "true"
+	}
+	return os.Getenv("MESSAGE_ENVELOPE") == "true"
+}
+
+func LLMOpenAIBaseURL() string {
+	return os.Getenv("LLM_OPENAI_BASE_URL")
+}
+
+func LLMOpenAIApiKey() string {
+	return os.Getenv("LLM_OPENAI_API_KEY")
+}
+
+func LLMOpenAIModel() string {
+	return os.Getenv("LLM_OPENAI_MODEL")
+}
+
+func LLMOpenAITimeout() time.Duration {
+	d, err := time.ParseDuration(os.Getenv("LLM_OPENAI_TIMEOUT"))
+	if err != nil || d <= 0 {
+		return defaultLLMOpenAITimeout
+	}
+	return d
+}
+
+// PromptTemplatesDir is the directory the prompt templates are loaded from,
+// the templates shipped with the build being used when it is not set.
+func PromptTemplatesDir() string {
+	return os.Getenv("PROMPT_TEMPLATES_DIR")
+}
+
+// PromptTemplateVersion pins the version of a prompt template with
+// PROMPT_TEMPLATE_VERSION_<NAME> (e.g. PROMPT_TEMPLATE_VERSION_SUMMARIZE=v4).
+func PromptTemplateVersion(name string) string {
+	return os.Getenv("PROMPT_TEMPLATE_VERSION_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")))
+}
+
+// AcceptancePolicy tells how answers with some invalid codes are handled by an
+// action, set by ACCEPTANCE_POLICY_<ACTION> (e.g. ACCEPTANCE_POLICY_LOCATION).
+func AcceptancePolicy(action string) string {
+	if policy := os.Getenv("ACCEPTANCE_POLICY_" + strings.ToUpper(action)); policy != "" {
+		return policy
+	}
+	return defaultAcceptancePolicy
+}
+
+func AcceptanceMinValid(action string) int {
+	i, _ := strconv.Atoi(os.Getenv("ACCEPTANCE_MIN_VALID_" + strings.ToUpper(action)))
+	return i
+}
+
+// ChunkTokenBudget is the size, in tokens, above which web page contents are
+// summarized by chunks.
+func ChunkTokenBudget() int {
+	i, err := strconv.Atoi(os.Getenv("CHUNK_TOKEN_BUDGET"))
+	if err != nil || i <= 0 {
+		return defaultChunkTokenBudget
+	}
+	return i
+}
+
+func ResponseCacheEnabled() bool {
+	return os.Getenv("RESPONSE_CACHE_ENABLED") != "false"
+}
+
+// ResponseCacheTTL is how long an accepted answer is reused for the same
+// prompt.
+func ResponseCacheTTL() time.Duration {
+	d, err := time.ParseDuration(os.Getenv("RESPONSE_CACHE_TTL"))
+	if err != nil || d <= 0 {
+		return defaultResponseCacheTTL
+	}
+	return d
+}
+
+// EnsembleVoters are the providers voting on the yes/no decision of an action,
+// set by ENSEMBLE_VOTERS_<ACTION> as a comma separated list (e.g.
+// ENSEMBLE_VOTERS_WORTH_CHECKING=gemini,gemini,openai samples gemini twice).
+// Actions without voters are decided by a single answer.
+func EnsembleVoters(action string) []string {
+	var voters []string
+	for _, voter := range strings.Split(os.Getenv("ENSEMBLE_VOTERS_"+strings.ToUpper(strings.ReplaceAll(action, "-", "_"))), ",") {
+		if voter = strings.TrimSpace(voter); voter != "" {
+			voters = append(voters, voter)
+		}
+	}
+	return voters
+}
+
+// EnsembleRule is how the votes of an action are aggregated: majority, any-yes
+// or unanimous.
+func EnsembleRule(action string) string {
+	if rule := os.Getenv("ENSEMBLE_RULE_" + strings.ToUpper(strings.ReplaceAll(action, "-", "_"))); rule != "" {
+		return rule
+	}
+	return defaultEnsembleRule
+}
+
+// RelevanceThreshold is the score, from 0 to 100, a research must reach on an
+// action to go further when its request sets no threshold, set by
+// RELEVANCE_THRESHOLD_<ACTION> (e.g. RELEVANCE_THRESHOLD_WORTH_SUMMARIZE).
+func RelevanceThreshold(action string) int {
+	i, err := strconv.Atoi(os.Getenv("RELEVANCE_THRESHOLD_" + strings.ToUpper(strings.ReplaceAll(action, "-", "_"))))
+	if err != nil || i < 0 || i > 100 {
+		return defaultRelevanceThreshold
+	}
+	return i
+}
@@ -3,12 +3,12 @@ package controllers
 import (
 	"context"
 	"errors"
+	"fmt"
 	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/config/errortypes"
 	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/config/properties"
 	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/delivery/dtos"
 	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/delivery/parser"
 	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/delivery/validations"
-	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/builder"
 	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/interfaces"
 	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/models"
 	"github.com/PesquisAi/pesquisai-errors-lib/exceptions"
@@ -17,11 +17,64 @@ import (
 )
 
 type controller struct {
-	useCase     interfaces.UseCase
-	queueGemini interfaces.Queue
+	useCase                          interfaces.UseCase
+	promptDispatcher                 interfaces.PromptDispatcher
+	deduplicationStore               interfaces.DeduplicationStore
+	deadLetterAiOrchestrator         interfaces.DeadLetterQueue
+	deadLetterAiOrchestratorCallback interfaces.DeadLetterQueue
 }
 
-func (c controller) errorHandler(ctx context.Context, err error) error {
+// retryable tells whether the consumer retry may succeed where the delivery
+// failed. Invalid and unroutable messages fail the same way every time.
+func retryable(exception *exceptions.Error) bool {
+	return exception.Code != errortypes.ValidateCode &&
+		exception.Code != errortypes.ServiceNotFoundCode
+}
+
+// retryCount is how many times the consumer already retried the delivery.
+func retryCount(delivery amqp.Delivery) int {
+	retry, _ := delivery.Headers["x-retry-count"].(int32)
+	return int(retry)
+}
+
+// release forgets the processed attempt of a callback, so its next delivery
+// (a consumer retry or a reinjected dead letter) is processed instead of
+// being acknowledged as a duplicate.
+func (c controller) release(ctx context.Context, callback *models.AiOrchestratorCallbackRequest) {
+	if callback == nil {
+		return
+	}
+
+	err := c.deduplicationStore.Release(ctx, *callback)
+	if err != nil {
+		slog.Warn("controller.release",
+			slog.String("details", "could not release callback attempt"),
+			slog.String("error", err.Error()))
+	}
+}
+
+// deadLetter moves the delivery to the dead letter queue of its consumer, the
+// failure travelling in its headers. The delivery is left to the consumer
+// retry when it can not be dead-lettered.
+func (c controller) deadLetter(ctx context.Context, deadLetters interfaces.DeadLetterQueue, delivery amqp.Delivery, callback *models.AiOrchestratorCallbackRequest, exception *exceptions.Error) error {
+	err := deadLetters.Publish(ctx, delivery, exception.Code, exception.Messages...)
+	if err != nil {
+		slog.Error("controller.deadLetter",
+			slog.String("details", "process error"),
+			slog.String("error", err.Error()))
+		return exception
+	}
+	c.release(ctx, callback)
+
+	slog.Warn("controller.deadLetter",
+		slog.String("details", "message dead-lettered"),
+		slog.String("code", exception.Code))
+	return nil
+}
+
+// errorHandler settles a failed delivery. The callback is given once the use
+// case ran, as its attempt may be recorded as processed.
+func (c controller) errorHandler(ctx context.Context, deadLetters interfaces.DeadLetterQueue, delivery amqp.Delivery, callback *models.AiOrchestratorCallbackRequest, err error) error {
 	var exception *exceptions.Error
 	if !errors.As(err, &exception) {
 		exception = errortypes.NewUnknownException(err.Error())
@@ -35,48 +88,72 @@ func (c controller) errorHandler(ctx context.Context, err error) error {
 	if exception.Code == errortypes.InvalidAiResponseCode {
 		receiveCount, _ := exception.Forward["receiveCount"].(int)
 		if receiveCount >= properties.GetMaxAiReceiveCount() {
-			return nil
+			exception.Messages = append(exception.Messages,
+				fmt.Sprintf("no valid answer after %d attempts", properties.GetMaxAiReceiveCount()))
+			return c.deadLetter(ctx, deadLetters, delivery, callback, exception)
 		}
 
 		requestId, _ := exception.Forward["requestId"].(string)
 		question, _ := exception.Forward["question"].(string)
 		action, _ := exception.Forward["action"].(string)
 
-		b, err = builder.BuildQueueGeminiMessage(requestId, question, properties.QueueNameAiOrchestratorCallback, action, receiveCount)
-		if err != nil {
-			slog.Error("controller.errorHandler",
-				slog.String("details", "process error"),
-				slog.Any("err", err.Error()))
-		} else {
-			err = c.queueGemini.Publish(ctx, b)
-			if err == nil {
-				return nil
-			}
-			slog.Warn("controller.errorHandler",
-				slog.String("details", "process error"),
-				slog.Any("err", err.Error()))
+		var researchId, templateVersion *string
+		if id, ok := exception.Forward["researchId"].(string); ok {
+			researchId = &id
+		}
+		if version, ok := exception.Forward["templateVersion"].(string); ok {
+			templateVersion = &version
+		}
+
+		var chunk, voter *int
+		if i, ok := exception.Forward["chunk"].(int); ok {
+			chunk = &i
+		}
+		if i, ok := exception.Forward["voter"].(int); ok {
+			voter = &i
 		}
+
+		err = c.promptDispatcher.Dispatch(ctx, models.Prompt{
+			RequestId:       &requestId,
+			ResearchId:      researchId,
+			Action:          &action,
+			Question:        &question,
+			TemplateVersion: templateVersion,
+			Chunk:           chunk,
+			Voter:           voter,
+			ReceiveCount:    receiveCount,
+		})
+		if err == nil {
+			return nil
+		}
+		slog.Warn("controller.errorHandler",
+			slog.String("details", "process error"),
+			slog.Any("err", err.Error()))
+		c.release(ctx, callback)
 	}
 
 	if exception.Abort {
 		return nil
 	}
+	if !retryable(exception) || retryCount(delivery) >= properties.QueueMaxRetries() {
+		return c.deadLetter(ctx, deadLetters, delivery, callback, exception)
+	}
 	return exception
 }
 
-func (c controller) def() {
+func (c controller) def(deadLetters interfaces.DeadLetterQueue, delivery amqp.Delivery) {
 	if r := recover(); r != nil {
 		slog.Error("controller.def",
 			slog.String("details", "process panic"),
 			slog.Any("recover", r))
 
-		err := errortypes.NewUnknownException("application panic")
-		_ = c.errorHandler(context.TODO(), err)
+		err := errortypes.NewUnknownException(fmt.Sprintf("application panic: %v", r))
+		_ = c.deadLetter(context.TODO(), deadLetters, delivery, nil, err)
 	}
 }
 
 func (c controller) AiOrchestratorHandler(delivery amqp.Delivery) error {
-	defer c.def()
+	defer c.def(c.deadLetterAiOrchestrator, delivery)
 	ctx := context.Background()
 	slog.Info("controller.AiOrchestratorHandler",
 		slog.String("details", "process started"),
@@ -86,25 +163,38 @@ func (c controller) AiOrchestratorHandler(delivery amqp.Delivery) error {
 	var request dtos.AiOrchestratorRequest
 	err := parser.ParseDeliveryJSON(&request, delivery)
 	if err != nil {
-		return c.errorHandler(ctx, err)
+		return c.errorHandler(ctx, c.deadLetterAiOrchestrator, delivery, nil, err)
 	}
 
 	err = validations.ValidateRequest(&request)
 	if err != nil {
-		return c.errorHandler(ctx, err)
+		return c.errorHandler(ctx, c.deadLetterAiOrchestrator, delivery, nil, err)
 	}
 
 	requestModel := models.AiOrchestratorRequest{
-		RequestId:  request.RequestId,
-		ResearchId: request.ResearchId,
-		Context:    request.Context,
-		Research:   request.Research,
-		Action:     request.Action,
+		RequestId:      request.RequestId,
+		ResearchId:     request.ResearchId,
+		Context:        request.Context,
+		Research:       request.Research,
+		Action:         request.Action,
+		ReplayAction:   request.ReplayAction,
+		Thresholds:     request.Thresholds,
+		OutputLanguage: request.OutputLanguage,
+	}
+
+	if request.Options != nil {
+		requestModel.Options = &models.Options{
+			SentenceCount: request.Options.SentenceCount,
+			MaxSources:    request.Options.MaxSources,
+			SummaryLength: request.Options.SummaryLength,
+			SummaryStyle:  request.Options.SummaryStyle,
+			Stages:        request.Options.Stages,
+		}
 	}
 
 	err = c.useCase.Orchestrate(ctx, requestModel)
 	if err != nil {
-		return c.errorHandler(ctx, err)
+		return c.errorHandler(ctx, c.deadLetterAiOrchestrator, delivery, nil, err)
 	}
 
 	slog.Info("controller.AiOrchestratorHandler",
@@ -113,7 +203,7 @@ func (c controller) AiOrchestratorHandler(delivery amqp.Delivery) error {
 }
 
 func (c controller) AiOrchestratorCallbackHandler(delivery amqp.Delivery) error {
-	defer c.def()
+	defer c.def(c.deadLetterAiOrchestratorCallback, delivery)
 	ctx := context.Background()
 
 	slog.Info("controller.AiOrchestratorCallbackHandler",
@@ -124,25 +214,36 @@ func (c controller) AiOrchestratorCallbackHandler(delivery amqp.Delivery) error
 	var callback dtos.AiOrchestratorCallbackRequest
 	err := parser.ParseDeliveryJSON(&callback, delivery)
 	if err != nil {
-		return c.errorHandler(ctx, err)
+		return c.errorHandler(ctx, c.deadLetterAiOrchestratorCallback, delivery, nil, err)
 	}
 
 	err = validations.ValidateCallbackRequest(&callback)
 	if err != nil {
-		return c.errorHandler(ctx, err)
+		return c.errorHandler(ctx, c.deadLetterAiOrchestratorCallback, delivery, nil, err)
+	}
+
+	researchId := callback.ResearchId
+	if researchId == nil {
+		researchId = callback.Forward.ResearchId
 	}
 
 	requestModel := models.AiOrchestratorCallbackRequest{
-		RequestId:    callback.RequestId,
-		ResearchId:   callback.ResearchId,
-		Response:     callback.Response,
-		Action:       callback.Forward.Action,
-		ReceiveCount: *callback.Forward.ReceiveCount,
+		RequestId:       callback.RequestId,
+		ResearchId:      researchId,
+		Response:        callback.Response,
+		Action:          callback.Forward.Action,
+		Chunk:           callback.Forward.Chunk,
+		Voter:           callback.Forward.Voter,
+		ReceiveCount:    *callback.Forward.ReceiveCount,
+		DispatchId:      callback.Forward.DispatchId,
+		TemplateVersion: callback.Forward.TemplateVersion,
+		DispatchedAt:    callback.Forward.DispatchedAt,
+		Cached:          callback.Forward.Cached,
 	}
 
 	err = c.useCase.OrchestrateCallback(ctx, requestModel)
 	if err != nil {
-		return c.errorHandler(ctx, err)
+		return c.errorHandler(ctx, c.deadLetterAiOrchestratorCallback, delivery, &requestModel, err)
 	}
 
 	slog.Info("controller.AiOrchestratorCallbackHandler",
@@ -151,9 +252,12 @@ func (c controller) AiOrchestratorCallbackHandler(delivery amqp.Delivery) error
 	return nil
 }
 
-func NewController(queueGemini interfaces.Queue, useCase interfaces.UseCase) interfaces.Controller {
+func NewController(promptDispatcher interfaces.PromptDispatcher, deduplicationStore interfaces.DeduplicationStore, useCase interfaces.UseCase, deadLetterAiOrchestrator, deadLetterAiOrchestratorCallback interfaces.DeadLetterQueue) interfaces.Controller {
 	return &controller{
-		useCase:     useCase,
-		queueGemini: queueGemini,
+		useCase:                          useCase,
+		promptDispatcher:                 promptDispatcher,
+		deduplicationStore:               deduplicationStore,
+		deadLetterAiOrchestrator:         deadLetterAiOrchestrator,
+		deadLetterAiOrchestratorCallback: deadLetterAiOrchestratorCallback,
 	}
 }
@@ -1,11 +1,23 @@
 package dtos
 
+import (
+	"time"
+)
+
 type AiOrchestratorCallbackRequest struct {
 	RequestId  *string `json:"request_id,omitempty" validate:"uuid"`
 	ResearchId *string `json:"research_id,omitempty" validate:"omitempty,uuid"`
 	Response   *string `json:"response,omitempty" validate:"required"`
 	Forward    *struct {
-		Action       *string `json:"action" validate:"required,oneof= location language sentences worth-checking worth-summarizing summarize"`
-		ReceiveCount *int    `json:"receive_count" validate:"required"`
+		Action       *string    `json:"action" validate:"required,oneof= location language sentences worth-checking worth-summarize summarize summarize-chunk overall"`
+		ReceiveCount *int       `json:"receive_count" validate:"required"`
+		DispatchId   *string    `json:"dispatch_id,omitempty"`
+		ResearchId   *string    `json:"research_id,omitempty" validate:"omitempty,uuid"`
+		Chunk        *int       `json:"chunk,omitempty" validate:"omitempty,min=0"`
+		Voter        *int       `json:"voter,omitempty" validate:"omitempty,min=0"`
+		DispatchedAt *time.Time `json:"dispatched_at,omitempty"`
+		Cached       bool       `json:"cached,omitempty"`
+		// TemplateVersion is the version of the prompt template answered.
+		TemplateVersion *string `json:"template_version,omitempty"`
 	} `json:"forward" validate:"required"`
 }
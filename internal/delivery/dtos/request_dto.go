@@ -1,9 +1,29 @@
 package dtos
 
 type AiOrchestratorRequest struct {
-	RequestId  *string `json:"request_id" validate:"uuid,required"`
-	ResearchId *string `json:"research_id"`
-	Context    *string `json:"context"`
-	Research   *string `json:"research"`
-	Action     *string `json:"action" validate:"required,oneof= location language sentences worth-checking worth-summarizing summarize"`
+	RequestId    *string `json:"request_id" validate:"uuid,required"`
+	ResearchId   *string `json:"research_id"`
+	Context      *string `json:"context"`
+	Research     *string `json:"research"`
+	Action       *string `json:"action" validate:"required,oneof= location language sentences worth-checking worth-summarize summarize overall cancel replay"`
+	ReplayAction *string `json:"replay_action,omitempty" validate:"required_if=Action replay"`
+	// OutputLanguage is the language code summaries and reports are written
+	// in, detected from the context and research when not given.
+	OutputLanguage *string `json:"output_language,omitempty" validate:"omitempty,len=2,lowercase"`
+	// Thresholds are the relevance scores, from 0 to 100, a research must reach
+	// to be accessed (worth-checking) or summarized (worth-summarize).
+	Thresholds map[string]int         `json:"thresholds,omitempty" validate:"omitempty,dive,keys,oneof=worth-checking worth-summarize,endkeys,min=0,max=100"`
+	Options    *AiOrchestratorOptions `json:"options,omitempty"`
+}
+
+// AiOrchestratorOptions tune how a request is researched. Options left out
+// keep their defaults: 5 sentences, no source limit, short prose summaries and
+// every stage enabled.
+type AiOrchestratorOptions struct {
+	SentenceCount *int    `json:"sentence_count,omitempty" validate:"omitempty,min=1,max=20"`
+	MaxSources    *int    `json:"max_sources,omitempty" validate:"omitempty,min=1"`
+	SummaryLength *string `json:"summary_length,omitempty" validate:"omitempty,oneof=short medium long"`
+	SummaryStyle  *string `json:"summary_style,omitempty" validate:"omitempty,oneof=prose bullets"`
+	// Stages turns the optional stages on or off.
+	Stages map[string]bool `json:"stages,omitempty" validate:"omitempty,dive,keys,oneof=worth-checking worth-summarize summarize overall,endkeys"`
 }
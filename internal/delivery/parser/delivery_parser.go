@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/config/errortypes"
+	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/builder"
 	"github.com/PesquisAi/pesquisai-rabbitmq-lib/rabbitmq"
 	"github.com/rabbitmq/amqp091-go"
 )
@@ -15,5 +16,10 @@ func ParseDeliveryJSON(out interface{}, delivery amqp091.Delivery) error {
 				delivery.ContentType, rabbitmq.ContentTypeJson))
 	}
 
-	return json.Unmarshal(delivery.Body, out)
+	payload, err := builder.Unwrap(delivery.Body)
+	if err != nil {
+		return errortypes.NewValidationException(err.Error())
+	}
+
+	return json.Unmarshal(payload, out)
 }
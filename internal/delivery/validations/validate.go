@@ -5,14 +5,19 @@ import (
 	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/config/errortypes"
 	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/delivery/dtos"
 	"github.com/go-playground/validator/v10"
+	"strings"
 )
 
 func getError(tag string, field string) string {
 	switch tag {
-	case "required":
+	case "required", "required_if":
 		return fmt.Sprintf("'%s' is required", field)
 	case "max":
 		switch field {
+		case "thresholds":
+			return "'thresholds' should be between '0' and '100'"
+		case "options.sentence_count":
+			return "'options.sentence_count' should be at most '20'"
 		case "context":
 			return "'context' should have at most '1000' characters"
 		case "research":
@@ -20,6 +25,10 @@ func getError(tag string, field string) string {
 		}
 	case "min":
 		switch field {
+		case "thresholds":
+			return "'thresholds' should be between '0' and '100'"
+		case "options.sentence_count", "options.max_sources":
+			return fmt.Sprintf("'%s' should be at least '1'", field)
 		case "context":
 			return "'context' should have at least '100' characters"
 		case "research":
@@ -27,6 +36,12 @@ func getError(tag string, field string) string {
 		}
 	case "uuid":
 		return fmt.Sprintf("'%s' should be an uuid", field)
+	case "len", "lowercase":
+		if field == "output_language" {
+			return "'output_language' should be a two letter lowercase language code"
+		}
+	case "oneof":
+		return fmt.Sprintf("'%s' has an invalid value", field)
 	}
 
 	return ""
@@ -37,6 +52,26 @@ func getField(field string) string {
 		return "research"
 	case "Context":
 		return "context"
+	case "ReplayAction":
+		return "replay_action"
+	case "Action":
+		return "action"
+	case "OutputLanguage":
+		return "output_language"
+	case "SentenceCount":
+		return "options.sentence_count"
+	case "MaxSources":
+		return "options.max_sources"
+	case "SummaryLength":
+		return "options.summary_length"
+	case "SummaryStyle":
+		return "options.summary_style"
+	}
+	if strings.HasPrefix(field, "Stages") {
+		return "options.stages"
+	}
+	if strings.HasPrefix(field, "Thresholds") {
+		return "thresholds"
 	}
 	return ""
 }
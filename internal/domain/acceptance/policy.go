@@ -0,0 +1,54 @@
+package acceptance
+
+import (
+	"fmt"
+	enumpolicies "github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/enums/policies"
+)
+
+// Policy decides whether an answer listing codes is accepted when some of its
+// codes are invalid:
+//   - strict rejects the answer if any code is invalid;
+//   - filter-invalid keeps the valid codes, as long as there is one;
+//   - min-valid-count keeps the valid codes, as long as there are MinValid.
+//
+// Answers without invalid codes are accepted by every policy, whatever the
+// number of codes they list.
+type Policy struct {
+	Mode     string
+	MinValid int
+}
+
+// Reject tells why an answer with valid codes and the given problems is
+// rejected, or nil when it is accepted.
+func (p Policy) Reject(valid int, problems []string) []string {
+	if len(problems) == 0 {
+		return nil
+	}
+
+	switch p.Mode {
+	case enumpolicies.FilterInvalid:
+		if valid == 0 {
+			return append(problems, "at least one valid code is required")
+		}
+	case enumpolicies.MinValidCount:
+		if valid < p.MinValid {
+			return append(problems, fmt.Sprintf("at least %d valid codes are required, %d were given", p.MinValid, valid))
+		}
+	default:
+		return problems
+	}
+	return nil
+}
+
+func NewPolicy(mode string, minValid int) (Policy, error) {
+	switch mode {
+	case enumpolicies.Strict, enumpolicies.FilterInvalid:
+	case enumpolicies.MinValidCount:
+		if minValid < 1 {
+			return Policy{}, fmt.Errorf("policy %s needs a minimum of valid codes greater than 0", mode)
+		}
+	default:
+		return Policy{}, fmt.Errorf("unknown acceptance policy '%s'", mode)
+	}
+	return Policy{Mode: mode, MinValid: minValid}, nil
+}
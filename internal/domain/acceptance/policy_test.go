@@ -0,0 +1,33 @@
+package acceptance
+
+import (
+	enumpolicies "github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/enums/policies"
+	"testing"
+)
+
+func TestPolicyReject(t *testing.T) {
+	problems := []string{`"xx" is not a valid code`}
+	tests := []struct {
+		name     string
+		policy   Policy
+		valid    int
+		problems []string
+		rejected bool
+	}{
+		{"strict accepts valid codes", Policy{Mode: enumpolicies.Strict}, 2, nil, false},
+		{"strict rejects an invalid code", Policy{Mode: enumpolicies.Strict}, 2, problems, true},
+		{"filter-invalid keeps the valid codes", Policy{Mode: enumpolicies.FilterInvalid}, 1, problems, false},
+		{"filter-invalid rejects without valid codes", Policy{Mode: enumpolicies.FilterInvalid}, 0, problems, true},
+		{"min-valid-count keeps enough valid codes", Policy{Mode: enumpolicies.MinValidCount, MinValid: 2}, 2, problems, false},
+		{"min-valid-count rejects too few valid codes", Policy{Mode: enumpolicies.MinValidCount, MinValid: 2}, 1, problems, true},
+		{"min-valid-count accepts fewer codes when none is invalid", Policy{Mode: enumpolicies.MinValidCount, MinValid: 3}, 1, nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.policy.Reject(tt.valid, tt.problems)
+			if (got != nil) != tt.rejected {
+				t.Errorf("Reject(%d, %v) = %v, rejected %v", tt.valid, tt.problems, got, tt.rejected)
+			}
+		})
+	}
+}
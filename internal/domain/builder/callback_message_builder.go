@@ -0,0 +1,26 @@
+package builder
+
+import (
+	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/config/properties"
+	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/models"
+)
+
+type callbackMessage struct {
+	RequestId  *string         `json:"request_id"`
+	ResearchId *string         `json:"research_id,omitempty"`
+	Response   *string         `json:"response"`
+	Forward    *map[string]any `json:"forward"`
+}
+
+func BuildQueueCallbackMessage(prompt models.Prompt, response string) ([]byte, error) {
+	forward := buildForward(prompt)
+
+	msg := &callbackMessage{
+		RequestId:  prompt.RequestId,
+		ResearchId: prompt.ResearchId,
+		Response:   &response,
+		Forward:    &forward,
+	}
+
+	return marshal(properties.QueueNameAiOrchestratorCallback, TypeCallback, prompt.RequestId, msg)
+}
@@ -0,0 +1,79 @@
+package builder
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/config/properties"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+// EnvelopeSchemaVersion is the version of the envelope the messages are
+// published in, the consumers accepting it and every previous one.
+const EnvelopeSchemaVersion = 1
+
+// Types of the messages, telling the consumers what the payload holds.
+const (
+	TypeOrchestratorRequest = "orchestrator-request"
+	TypePrompt              = "llm-prompt"
+	TypeCallback            = "llm-callback"
+	TypeGoogleSearch        = "google-search-request"
+	TypeWebScraper          = "web-scraper-request"
+	TypeStatus              = "status-update"
+)
+
+// Envelope wraps the payload of a queue message. The correlation id is the
+// request the message belongs to, shared by every message of its flow. It is
+// left empty when the message does not carry its request id.
+type Envelope struct {
+	SchemaVersion int             `json:"schema_version"`
+	Type          string          `json:"type"`
+	MessageId     string          `json:"message_id"`
+	CorrelationId string          `json:"correlation_id,omitempty"`
+	CreatedAt     time.Time       `json:"created_at"`
+	Payload       json.RawMessage `json:"payload"`
+}
+
+// marshal encodes the payload of a message to the queue, in the envelope once
+// the consumers of the queue accept it and bare otherwise.
+func marshal(queue, messageType string, correlationId *string, payload any) ([]byte, error) {
+	b, err := json.Marshal(payload)
+	if err != nil || !properties.MessageEnvelope(queue) {
+		return b, err
+	}
+
+	envelope := Envelope{
+		SchemaVersion: EnvelopeSchemaVersion,
+		Type:          messageType,
+		MessageId:     primitive.NewObjectID().Hex(),
+		CreatedAt:     time.Now().UTC(),
+		Payload:       b,
+	}
+	if correlationId != nil {
+		envelope.CorrelationId = *correlationId
+	}
+	return json.Marshal(&envelope)
+}
+
+// Unwrap returns the payload of a message, whether it comes in the envelope
+// or in the bare shape published before it.
+func Unwrap(body []byte) ([]byte, error) {
+	var envelope struct {
+		SchemaVersion *int            `json:"schema_version"`
+		Payload       json.RawMessage `json:"payload"`
+	}
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		return nil, err
+	}
+
+	if envelope.SchemaVersion == nil {
+		return body, nil
+	}
+	if *envelope.SchemaVersion < 1 || *envelope.SchemaVersion > EnvelopeSchemaVersion {
+		return nil, fmt.Errorf("envelope schema version %d is not supported, the latest being %d", *envelope.SchemaVersion, EnvelopeSchemaVersion)
+	}
+	if envelope.Payload == nil {
+		return nil, fmt.Errorf(`envelope "payload" is required`)
+	}
+	return envelope.Payload, nil
+}
@@ -0,0 +1,91 @@
+package builder
+
+import (
+	"encoding/json"
+	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/config/properties"
+	"strings"
+	"testing"
+)
+
+func TestUnwrap(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		want    string
+		wantErr string
+	}{
+		{"legacy bare body", `{"request_id":"r","status":"FINISHED"}`, `{"request_id":"r","status":"FINISHED"}`, ""},
+		{"versioned envelope", `{"schema_version":1,"type":"status-update","message_id":"m","payload":{"request_id":"r"}}`, `{"request_id":"r"}`, ""},
+		{"unknown newer version", `{"schema_version":2,"payload":{"request_id":"r"}}`, "", "envelope schema version 2 is not supported, the latest being 1"},
+		{"unknown older version", `{"schema_version":0,"payload":{"request_id":"r"}}`, "", "envelope schema version 0 is not supported, the latest being 1"},
+		{"envelope without payload", `{"schema_version":1,"type":"status-update"}`, "", `envelope "payload" is required`},
+		{"not JSON", `status`, "", "invalid character"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Unwrap([]byte(tt.body))
+			if tt.wantErr != "" {
+				if err == nil || !strings.HasPrefix(err.Error(), tt.wantErr) {
+					t.Errorf("Unwrap(%s) error = %v, want %q", tt.body, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("Unwrap(%s) = %s, want %s", tt.body, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMessageEnvelopeByQueue(t *testing.T) {
+	requestId := "request"
+
+	tests := []struct {
+		name         string
+		envelope     string
+		queue        string
+		wantEnvelope bool
+	}{
+		{"disabled", "", "", false},
+		{"enabled for every queue", "true", "", true},
+		{"enabled for the queue only", "", "true", true},
+		{"disabled for the queue only", "true", "false", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("MESSAGE_ENVELOPE", tt.envelope)
+			t.Setenv("MESSAGE_ENVELOPE_STATUS_MANAGER", tt.queue)
+
+			b, err := BuildQueueStatusManagerMessage(&requestId, nil, "FINISHED")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var envelope Envelope
+			if err = json.Unmarshal(b, &envelope); err != nil {
+				t.Fatal(err)
+			}
+			if enveloped := envelope.SchemaVersion != 0; enveloped != tt.wantEnvelope {
+				t.Fatalf("%s message enveloped = %v, want %v: %s", properties.QueueNameStatusManager, enveloped, tt.wantEnvelope, b)
+			}
+			if tt.wantEnvelope && (envelope.Type != TypeStatus || envelope.CorrelationId != requestId || envelope.MessageId == "") {
+				t.Errorf("envelope = %+v, want a %s message correlated to %s", envelope, TypeStatus, requestId)
+			}
+
+			payload, err := Unwrap(b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var message statusManagerMessage
+			if err = json.Unmarshal(payload, &message); err != nil {
+				t.Fatal(err)
+			}
+			if message.RequestId == nil || *message.RequestId != requestId || message.Status != "FINISHED" {
+				t.Errorf("Unwrap() payload = %s, want the status of %s", payload, requestId)
+			}
+		})
+	}
+}
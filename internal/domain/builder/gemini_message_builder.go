@@ -1,27 +1,59 @@
 package builder
 
 import (
-	"encoding/json"
+	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/config/properties"
+	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/models"
 )
 
 type message struct {
 	RequestId   *string         `json:"request_id"`
+	ResearchId  *string         `json:"research_id,omitempty"`
 	Question    *string         `json:"question"`
 	OutputQueue *string         `json:"output_queue"`
 	Forward     *map[string]any `json:"forward"`
 }
 
-func BuildQueueGeminiMessage(requestId, question, outputQueue, action string, receiveCount int) ([]byte, error) {
+// buildForward is what the LLM worker echoes back with the answer to tell
+// which prompt it answers.
+func buildForward(prompt models.Prompt) map[string]any {
+	forward := map[string]any{
+		"action":        *prompt.Action,
+		"receive_count": prompt.ReceiveCount,
+	}
+	if prompt.ResearchId != nil {
+		forward["research_id"] = *prompt.ResearchId
+	}
+	if prompt.TemplateVersion != nil {
+		forward["template_version"] = *prompt.TemplateVersion
+	}
+	if prompt.Chunk != nil {
+		forward["chunk"] = *prompt.Chunk
+	}
+	if prompt.Voter != nil {
+		forward["voter"] = *prompt.Voter
+	}
+	if prompt.DispatchId != nil {
+		forward["dispatch_id"] = *prompt.DispatchId
+	}
+	if prompt.DispatchedAt != nil {
+		forward["dispatched_at"] = *prompt.DispatchedAt
+	}
+	if prompt.Cached {
+		forward["cached"] = true
+	}
+	return forward
+}
+
+func BuildQueueGeminiMessage(prompt models.Prompt, outputQueue string) ([]byte, error) {
+	forward := buildForward(prompt)
 
 	msg := &message{
-		RequestId:   &requestId,
-		Question:    &question,
+		RequestId:   prompt.RequestId,
+		ResearchId:  prompt.ResearchId,
+		Question:    prompt.Question,
 		OutputQueue: &outputQueue,
-		Forward: &map[string]any{
-			"action":        action,
-			"receive_count": receiveCount,
-		},
+		Forward:     &forward,
 	}
 
-	return json.Marshal(msg)
+	return marshal(properties.QueueNameGemini, TypePrompt, prompt.RequestId, msg)
 }
@@ -1,13 +1,33 @@
 package builder
 
 import (
-	"encoding/json"
+	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/config/properties"
+	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/models"
 )
 
+type googleSearchSentence struct {
+	Sentence string `json:"sentence"`
+	Language string `json:"language"`
+	Country  string `json:"country"`
+}
+
 type googleSearchMessage struct {
-	RequestId string `json:"request_id"`
+	RequestId string                 `json:"request_id"`
+	Sentences []googleSearchSentence `json:"sentences"`
 }
 
-func BuildQueueGoogleSearchMessage(requestId string) ([]byte, error) {
-	return json.Marshal(&googleSearchMessage{RequestId: requestId})
+func BuildQueueGoogleSearchMessage(requestId string, sentences []models.Sentence) ([]byte, error) {
+	msg := &googleSearchMessage{
+		RequestId: requestId,
+		Sentences: make([]googleSearchSentence, len(sentences)),
+	}
+	for i, sentence := range sentences {
+		msg.Sentences[i] = googleSearchSentence{
+			Sentence: sentence.Sentence,
+			Language: sentence.Language,
+			Country:  sentence.Country,
+		}
+	}
+
+	return marshal(properties.QueueNameGoogleSearch, TypeGoogleSearch, &requestId, msg)
 }
@@ -1,10 +1,10 @@
 package builder
 
 import (
-	"encoding/json"
+	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/config/properties"
 	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/delivery/dtos"
 )
 
 func BuildQueueOrchestratorMessage(orchestratorDto dtos.AiOrchestratorRequest) ([]byte, error) {
-	return json.Marshal(&orchestratorDto)
+	return marshal(properties.QueueNameAiOrchestrator, TypeOrchestratorRequest, orchestratorDto.RequestId, &orchestratorDto)
 }
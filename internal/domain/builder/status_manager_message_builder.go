@@ -1,13 +1,14 @@
 package builder
 
 import (
-	"encoding/json"
+	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/config/properties"
 )
 
 type statusManagerMessage struct {
 	RequestId  *string `json:"request_id,omitempty"`
 	ResearchId *string `json:"research_id,omitempty"`
 	Status     string  `json:"status"`
+	Overall    *string `json:"overall,omitempty"`
 }
 
 func BuildQueueStatusManagerMessage(requestId, researchId *string, status string) ([]byte, error) {
@@ -17,5 +18,15 @@ func BuildQueueStatusManagerMessage(requestId, researchId *string, status string
 		Status:     status,
 	}
 
-	return json.Marshal(msg)
+	return marshal(properties.QueueNameStatusManager, TypeStatus, requestId, msg)
+}
+
+func BuildQueueStatusManagerOverallMessage(requestId, status string, overall *string) ([]byte, error) {
+	msg := &statusManagerMessage{
+		RequestId: &requestId,
+		Status:    status,
+		Overall:   overall,
+	}
+
+	return marshal(properties.QueueNameStatusManager, TypeStatus, &requestId, msg)
 }
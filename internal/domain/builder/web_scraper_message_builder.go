@@ -1,7 +1,7 @@
 package builder
 
 import (
-	"encoding/json"
+	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/config/properties"
 )
 
 type queueMessage struct {
@@ -17,5 +17,5 @@ func BuildQueueWebScraperMessage(requestId, researchId, url string) ([]byte, err
 		Url:        url,
 	}
 
-	return json.Marshal(msg)
+	return marshal(properties.QueueNameWebScraper, TypeWebScraper, &requestId, msg)
 }
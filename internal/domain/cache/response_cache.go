@@ -0,0 +1,120 @@
+package cache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/dispatcher"
+	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/interfaces"
+	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// responseCache keeps the accepted answers of the LLM under the hash of their
+// prompt. The question of an answered prompt is read from its outstanding
+// prompt record, which is still there while the callback is processed.
+type responseCache struct {
+	cacheRepository  interfaces.CacheRepository
+	promptRepository interfaces.PromptRepository
+	disabled         bool
+}
+
+// Key hashes the action, the template version, the voter and the question,
+// with its whitespace normalized, of a prompt. The voter keeps the voters of an
+// ensemble from sharing one answer.
+func Key(action string, templateVersion *string, voter *int, question string) string {
+	version := ""
+	if templateVersion != nil {
+		version = *templateVersion
+	}
+	if voter != nil {
+		version += ":voter-" + strconv.Itoa(*voter)
+	}
+
+	h := sha256.New()
+	h.Write([]byte(action))
+	h.Write([]byte{0})
+	h.Write([]byte(version))
+	h.Write([]byte{0})
+	h.Write([]byte(strings.Join(strings.Fields(question), " ")))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (c responseCache) Lookup(ctx context.Context, prompt models.Prompt) (string, bool, error) {
+	if c.disabled {
+		return "", false, nil
+	}
+
+	var cached models.CachedResponse
+	err := c.cacheRepository.GetById(ctx, Key(*prompt.Action, prompt.TemplateVersion, prompt.Voter, *prompt.Question), &cached)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	if cached.Response == nil {
+		return "", false, nil
+	}
+	return *cached.Response, true, nil
+}
+
+// prompt returns the outstanding prompt answered by the callback, if it is
+// still recorded.
+func (c responseCache) prompt(ctx context.Context, callback models.AiOrchestratorCallbackRequest) (models.Prompt, bool, error) {
+	var prompt models.Prompt
+	err := c.promptRepository.GetById(ctx, dispatcher.PromptId(*callback.RequestId, callback.ResearchId, *callback.Action, callback.Chunk, callback.Voter), &prompt)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return prompt, false, nil
+	}
+	if err != nil {
+		return prompt, false, err
+	}
+	return prompt, prompt.Question != nil && prompt.ReceiveCount == callback.ReceiveCount, nil
+}
+
+func (c responseCache) Store(ctx context.Context, callback models.AiOrchestratorCallbackRequest) error {
+	if c.disabled {
+		return nil
+	}
+
+	prompt, ok, err := c.prompt(ctx, callback)
+	if err != nil || !ok {
+		return err
+	}
+
+	return c.cacheRepository.Upsert(ctx, Key(*callback.Action, prompt.TemplateVersion, callback.Voter, *prompt.Question), bson.M{
+		"action":          callback.Action,
+		"templateVersion": prompt.TemplateVersion,
+		"response":        callback.Response,
+		"createdAt":       time.Now().UTC(),
+	})
+}
+
+// Evict drops the answer of a prompt whose answer was rejected, so its retry
+// reaches the LLM instead of receiving the same answer again.
+func (c responseCache) Evict(ctx context.Context, callback models.AiOrchestratorCallbackRequest) error {
+	if c.disabled {
+		return nil
+	}
+
+	prompt, ok, err := c.prompt(ctx, callback)
+	if err != nil || !ok {
+		return err
+	}
+
+	return c.cacheRepository.Delete(ctx, Key(*callback.Action, prompt.TemplateVersion, callback.Voter, *prompt.Question))
+}
+
+func NewResponseCache(cacheRepository interfaces.CacheRepository, promptRepository interfaces.PromptRepository, disabled bool) interfaces.ResponseCache {
+	return &responseCache{
+		cacheRepository:  cacheRepository,
+		promptRepository: promptRepository,
+		disabled:         disabled,
+	}
+}
@@ -0,0 +1,84 @@
+package chunking
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// charsPerToken estimates the size of a token, close enough for budgeting the
+// prompts without depending on the tokenizer of each model.
+const charsPerToken = 4
+
+// separators are tried in order to split a text, so chunks break between
+// paragraphs, then lines, then sentences, then words.
+var separators = []string{"\n\n", "\n", ". ", " "}
+
+func hardSplit(text string, limit int) []string {
+	var pieces []string
+	for len(text) > limit {
+		end := limit
+		for end > 0 && !utf8.RuneStart(text[end]) {
+			end--
+		}
+		if end == 0 {
+			_, end = utf8.DecodeRuneInString(text)
+		}
+		pieces = append(pieces, text[:end])
+		text = text[end:]
+	}
+	return append(pieces, text)
+}
+
+func split(text string, limit int, separators []string) []string {
+	if len(text) <= limit {
+		return []string{text}
+	}
+	if len(separators) == 0 {
+		return hardSplit(text, limit)
+	}
+
+	var pieces []string
+	for _, part := range strings.SplitAfter(text, separators[0]) {
+		pieces = append(pieces, split(part, limit, separators[1:])...)
+	}
+	return pieces
+}
+
+// Split splits content into chunks of about budget tokens at most. Content
+// within the budget is returned as its single chunk.
+func Split(content string, budget int) []string {
+	limit := budget * charsPerToken
+	if len(content) <= limit {
+		return []string{content}
+	}
+
+	var (
+		chunks  []string
+		current strings.Builder
+	)
+	flush := func() {
+		if chunk := strings.TrimSpace(current.String()); chunk != "" {
+			chunks = append(chunks, chunk)
+		}
+		current.Reset()
+	}
+
+	for _, piece := range split(content, limit, separators) {
+		if current.Len()+len(piece) > limit {
+			flush()
+		}
+		current.WriteString(piece)
+	}
+	flush()
+	return chunks
+}
+
+// Digest joins the summaries of the chunks of a content, in order.
+func Digest(summaries []string) string {
+	var b strings.Builder
+	for i, summary := range summaries {
+		b.WriteString(fmt.Sprintf("part %d of %d: %s\n", i+1, len(summaries), summary))
+	}
+	return b.String()
+}
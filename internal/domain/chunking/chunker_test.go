@@ -0,0 +1,55 @@
+package chunking
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestSplit(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		budget  int
+		want    []string
+	}{
+		{"within the budget", "short content", 10, []string{"short content"}},
+		{"exactly the budget", strings.Repeat("a", 8), 2, []string{strings.Repeat("a", 8)}},
+		{"between paragraphs", "first paragraph\n\nsecond paragraph", 5, []string{"first paragraph", "second paragraph"}},
+		{"between lines", "first line here\nsecond line here", 4, []string{"first line here", "second line here"}},
+		{"between sentences", "First sentence. Second sentence.", 4, []string{"First sentence.", "Second sentence."}},
+		{"between words", "alpha beta gamma delta", 3, []string{"alpha beta", "gamma delta"}},
+		{"packs small pieces", "a\nb\nc\nd\ne\nf", 2, []string{"a\nb\nc\nd", "e\nf"}},
+		{"hard split of a long word", strings.Repeat("x", 10), 1, []string{"xxxx", "xxxx", "xx"}},
+		{"drops blank chunks", "first\n\n\n\n\n\n\n\nsecond", 1, []string{"firs", "t", "seco", "nd"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Split(tt.content, tt.budget)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Split(%q, %d) = %q, want %q", tt.content, tt.budget, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSplitKeepsRunes(t *testing.T) {
+	content := strings.Repeat("ação", 10)
+	for _, chunk := range Split(content, 1) {
+		if !utf8.ValidString(chunk) {
+			t.Errorf("Split() cut a rune in %q", chunk)
+		}
+		if len(chunk) > charsPerToken {
+			t.Errorf("Split() chunk %q is over the budget", chunk)
+		}
+	}
+}
+
+func TestDigest(t *testing.T) {
+	got := Digest([]string{"first", "second"})
+	want := "part 1 of 2: first\npart 2 of 2: second\n"
+	if got != want {
+		t.Errorf("Digest() = %q, want %q", got, want)
+	}
+}
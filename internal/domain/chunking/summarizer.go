@@ -0,0 +1,230 @@
+package chunking
+
+import (
+	"context"
+	"fmt"
+	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/delivery/dtos"
+	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/builder"
+	enumactions "github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/enums/actions"
+	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/interfaces"
+	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/models"
+	nosqlmodels "github.com/PesquisAi/pesquisai-database-lib/nosql/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"log/slog"
+	"strconv"
+	"time"
+)
+
+type chunkQuestion struct {
+	Context  string
+	Research string
+	Part     int
+	Parts    int
+	Content  string
+}
+
+// summarizer is the map side of the summarization of large contents: every
+// chunk is summarized on its own, tracked on the research document, and the
+// actions waiting for it are started again once all chunks are summarized.
+type summarizer struct {
+	promptDispatcher       interfaces.PromptDispatcher
+	promptRegistry         interfaces.PromptRegistry
+	orchestratorRepository interfaces.OrchestratorRepository
+	queueOrchestrator      interfaces.Queue
+	budget                 int
+}
+
+func summaries(chunks *models.Chunks) ([]string, bool) {
+	if chunks == nil || len(chunks.Summaries) < chunks.Total {
+		return nil, false
+	}
+
+	res := make([]string, chunks.Total)
+	for i := range res {
+		summary, ok := chunks.Summaries[strconv.Itoa(i)]
+		if !ok {
+			return nil, false
+		}
+		res[i] = summary
+	}
+	return res, true
+}
+
+// split splits the content of the research with the budget its chunks were
+// started with, the configured one before that.
+func (s summarizer) split(research models.OrchestratorResearch) []string {
+	budget := s.budget
+	if research.Chunks != nil && research.Chunks.Budget > 0 {
+		budget = research.Chunks.Budget
+	}
+	return Split(*research.Content, budget)
+}
+
+func (s summarizer) Question(request nosqlmodels.Request, research models.OrchestratorResearch, chunk int, templateVersion string) (string, string, error) {
+	chunks := s.split(research)
+	if chunk < 0 || chunk >= len(chunks) {
+		return "", "", fmt.Errorf("chunk %d out of the %d chunks of research %s", chunk, len(chunks), *research.ID)
+	}
+
+	return s.promptRegistry.Render(enumactions.SummarizeChunk, templateVersion, chunkQuestion{
+		Context:  *request.Context,
+		Research: *request.Research,
+		Part:     chunk + 1,
+		Parts:    len(chunks),
+		Content:  chunks[chunk],
+	})
+}
+
+func (s summarizer) dispatch(ctx context.Context, request nosqlmodels.Request, research models.OrchestratorResearch, chunk int) error {
+	outstanding, err := s.promptDispatcher.Outstanding(ctx, *request.ID, research.ID, enumactions.SummarizeChunk, &chunk, nil)
+	if err != nil || outstanding {
+		return err
+	}
+
+	question, version, err := s.Question(request, research, chunk, "")
+	if err != nil {
+		return err
+	}
+
+	action := enumactions.SummarizeChunk
+	return s.promptDispatcher.Dispatch(ctx, models.Prompt{
+		RequestId:       request.ID,
+		ResearchId:      research.ID,
+		Action:          &action,
+		Question:        &question,
+		TemplateVersion: &version,
+		Chunk:           &chunk,
+	})
+}
+
+func (s summarizer) Summaries(research models.OrchestratorResearch) ([]string, bool) {
+	if res, ok := summaries(research.Chunks); ok {
+		return res, true
+	}
+	return nil, len(s.split(research)) == 1
+}
+
+func (s summarizer) Prepare(ctx context.Context, request nosqlmodels.Request, research models.OrchestratorResearch, waiting string) ([]string, bool, error) {
+	if res, ok := s.Summaries(research); ok {
+		return res, true, nil
+	}
+
+	chunks := s.split(research)
+
+	slog.InfoContext(ctx, "summarizer.Prepare",
+		slog.String("details", "content too large, summarizing chunks"),
+		slog.Int("chunks", len(chunks)),
+		slog.String("waiting", waiting))
+
+	now := time.Now().UTC()
+	_, err := s.orchestratorRepository.UpdateOne(ctx,
+		bson.M{"_id": *research.ID, "chunks": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"chunks.total": len(chunks), "chunks.budget": s.budget, "chunks.startedAt": now}},
+	)
+	if err != nil {
+		return nil, false, err
+	}
+
+	// Only waits while the chunks are not mapped yet: once they are, the
+	// waiting actions were already read and this one would never be started.
+	var started models.OrchestratorResearch
+	added, err := s.orchestratorRepository.UpdateOneAndGet(ctx,
+		bson.M{"_id": *research.ID, "chunks.mappedAt": bson.M{"$exists": false}},
+		bson.M{"$addToSet": bson.M{"chunks.waiting": waiting}},
+		&started,
+	)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if !added {
+		var mapped models.OrchestratorResearch
+		err = s.orchestratorRepository.GetById(ctx, *research.ID, &mapped)
+		if err != nil {
+			return nil, false, err
+		}
+
+		res, ok := summaries(mapped.Chunks)
+		if !ok {
+			return nil, false, fmt.Errorf("chunks of research %s mapped without every summary", *research.ID)
+		}
+		return res, true, nil
+	}
+
+	chunks = s.split(started)
+	for i := range chunks {
+		if _, ok := started.Chunks.Summaries[strconv.Itoa(i)]; ok {
+			continue
+		}
+
+		err = s.dispatch(ctx, request, started, i)
+		if err != nil {
+			return nil, false, err
+		}
+	}
+	return nil, false, nil
+}
+
+func (s summarizer) Complete(ctx context.Context, requestId, researchId string, chunk int, summary string) error {
+	now := time.Now().UTC()
+
+	var research models.OrchestratorResearch
+	err := s.orchestratorRepository.FindOneAndUpdate(ctx, researchId, bson.M{
+		"$set": bson.M{
+			"chunks.summaries." + strconv.Itoa(chunk): summary,
+			"updatedAt": now,
+		},
+	}, &research)
+	if err != nil {
+		return err
+	}
+
+	if _, ok := summaries(research.Chunks); !ok {
+		return nil
+	}
+
+	// Only the callback that marks the chunks as mapped starts the waiting
+	// actions, so concurrent or redelivered chunks do not start them twice.
+	// The waiting actions are read by the same update, so an action added
+	// after it sees the chunks mapped instead of waiting forever.
+	var mapped models.OrchestratorResearch
+	claimed, err := s.orchestratorRepository.UpdateOneAndGet(ctx,
+		bson.M{"_id": researchId, "chunks.mappedAt": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"chunks.mappedAt": now}},
+		&mapped,
+	)
+	if err != nil || !claimed {
+		return err
+	}
+
+	for _, action := range mapped.Chunks.Waiting {
+		b, err := builder.BuildQueueOrchestratorMessage(dtos.AiOrchestratorRequest{
+			RequestId:  &requestId,
+			ResearchId: &researchId,
+			Action:     &action,
+		})
+		if err != nil {
+			return err
+		}
+
+		err = s.queueOrchestrator.Publish(ctx, b)
+		if err != nil {
+			return err
+		}
+	}
+
+	slog.InfoContext(ctx, "summarizer.Complete",
+		slog.String("details", "every chunk summarized"),
+		slog.Any("waiting", mapped.Chunks.Waiting))
+	return nil
+}
+
+func NewSummarizer(promptDispatcher interfaces.PromptDispatcher, promptRegistry interfaces.PromptRegistry, orchestratorRepository interfaces.OrchestratorRepository, queueOrchestrator interfaces.Queue, budget int) interfaces.ChunkSummarizer {
+	return &summarizer{
+		promptDispatcher:       promptDispatcher,
+		promptRegistry:         promptRegistry,
+		orchestratorRepository: orchestratorRepository,
+		queueOrchestrator:      queueOrchestrator,
+		budget:                 budget,
+	}
+}
@@ -0,0 +1,200 @@
+package deadletter
+
+import (
+	"context"
+	amqp "github.com/rabbitmq/amqp091-go"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"strings"
+	"time"
+)
+
+// Exchange routes the dead letters of every consumer to its dead letter
+// queue, the routing key being the name of the consumer queue.
+const Exchange = "dead-letter"
+
+// Headers of a dead letter, telling why it failed and where.
+const (
+	HeaderReason   = "x-failure-reason"
+	HeaderCode     = "x-failure-code"
+	HeaderQueue    = "x-original-queue"
+	HeaderPayload  = "x-original-payload"
+	HeaderFailedAt = "x-failed-at"
+)
+
+// Name is the dead letter queue of a consumer queue.
+func Name(queue string) string {
+	return queue + "-dlq"
+}
+
+// Letter is a dead-lettered message.
+type Letter struct {
+	Id       string
+	Queue    string
+	Code     string
+	Reason   string
+	FailedAt string
+	Headers  amqp.Table
+	Payload  []byte
+}
+
+type connection interface {
+	Channel() (*amqp.Channel, error)
+}
+
+// Queue is the dead letter queue of a consumer queue.
+type Queue struct {
+	connection connection
+	channel    *amqp.Channel
+	queue      string
+}
+
+func (q *Queue) Connect() (err error) {
+	if q.channel == nil {
+		q.channel, err = q.connection.Channel()
+		if err != nil {
+			return
+		}
+	}
+
+	err = q.channel.ExchangeDeclare(Exchange, amqp.ExchangeDirect, true, false, false, false, nil)
+	if err != nil {
+		return
+	}
+
+	// Declared like the dead letter queue of the rabbitmq lib, so both can
+	// share it.
+	_, err = q.channel.QueueDeclare(Name(q.queue), false, false, false, false, nil)
+	if err != nil {
+		return
+	}
+
+	return q.channel.QueueBind(Name(q.queue), q.queue, Exchange, false, nil)
+}
+
+// Publish dead-letters a delivery of the consumer queue with the code and the
+// reasons of its failure.
+func (q *Queue) Publish(ctx context.Context, delivery amqp.Delivery, code string, reasons ...string) error {
+	headers := amqp.Table{}
+	for key, value := range delivery.Headers {
+		headers[key] = value
+	}
+	headers[HeaderReason] = strings.Join(reasons, "; ")
+	headers[HeaderCode] = code
+	headers[HeaderQueue] = q.queue
+	headers[HeaderPayload] = string(delivery.Body)
+	headers[HeaderFailedAt] = time.Now().UTC().Format(time.RFC3339)
+
+	return q.channel.PublishWithContext(ctx, Exchange, q.queue, false, false, amqp.Publishing{
+		Headers:     headers,
+		MessageId:   primitive.NewObjectID().Hex(),
+		ContentType: delivery.ContentType,
+		Body:        delivery.Body,
+	})
+}
+
+// fetch takes up to limit dead letters, every one of them when limit is not
+// positive. They stay unacknowledged until the caller settles them.
+func (q *Queue) fetch(limit int) ([]amqp.Delivery, error) {
+	var deliveries []amqp.Delivery
+	for limit <= 0 || len(deliveries) < limit {
+		delivery, ok, err := q.channel.Get(Name(q.queue), false)
+		if err != nil {
+			return deliveries, err
+		}
+		if !ok {
+			break
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	return deliveries, nil
+}
+
+// release puts the fetched dead letters back in the queue.
+func (q *Queue) release(deliveries []amqp.Delivery) error {
+	if len(deliveries) == 0 {
+		return nil
+	}
+	return q.channel.Nack(deliveries[len(deliveries)-1].DeliveryTag, true, true)
+}
+
+func letter(delivery amqp.Delivery) Letter {
+	header := func(key string) string {
+		value, _ := delivery.Headers[key].(string)
+		return value
+	}
+	return Letter{
+		Id:       delivery.MessageId,
+		Queue:    header(HeaderQueue),
+		Code:     header(HeaderCode),
+		Reason:   header(HeaderReason),
+		FailedAt: header(HeaderFailedAt),
+		Headers:  delivery.Headers,
+		Payload:  delivery.Body,
+	}
+}
+
+// List returns up to limit dead letters, every one of them when limit is not
+// positive, leaving them in the queue.
+func (q *Queue) List(limit int) ([]Letter, error) {
+	deliveries, err := q.fetch(limit)
+	if err != nil {
+		_ = q.release(deliveries)
+		return nil, err
+	}
+
+	letters := make([]Letter, len(deliveries))
+	for i, delivery := range deliveries {
+		letters[i] = letter(delivery)
+	}
+	return letters, q.release(deliveries)
+}
+
+// Reinject publishes the dead letter with the given id back to the queue it
+// failed in and removes it from the dead letter queue. It tells whether the
+// dead letter was found.
+func (q *Queue) Reinject(ctx context.Context, id string) (bool, error) {
+	deliveries, err := q.fetch(0)
+	if err != nil {
+		_ = q.release(deliveries)
+		return false, err
+	}
+
+	var rest []amqp.Delivery
+	found := false
+	for _, delivery := range deliveries {
+		if found || delivery.MessageId != id {
+			rest = append(rest, delivery)
+			continue
+		}
+		found = true
+
+		err = q.channel.PublishWithContext(ctx, "", q.queue, false, false, amqp.Publishing{
+			ContentType: delivery.ContentType,
+			Body:        delivery.Body,
+		})
+		if err == nil {
+			err = delivery.Ack(false)
+		}
+		if err != nil {
+			_ = q.release(deliveries)
+			return false, err
+		}
+	}
+
+	// Released one by one: a multiple nack up to the last fetched dead letter
+	// fails when that one is the reinjected one, already acknowledged.
+	for _, delivery := range rest {
+		err = delivery.Nack(false, true)
+		if err != nil {
+			return found, err
+		}
+	}
+	return found, nil
+}
+
+func NewQueue(connection connection, queue string) *Queue {
+	return &Queue{
+		connection: connection,
+		queue:      queue,
+	}
+}
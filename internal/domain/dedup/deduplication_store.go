@@ -0,0 +1,88 @@
+package dedup
+
+import (
+	"context"
+	"fmt"
+	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/interfaces"
+	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"strings"
+	"time"
+)
+
+const (
+	statusProcessing = "processing"
+	statusDone       = "done"
+
+	// lease is how long a processing claim holds before another delivery of the
+	// same attempt may take it over, e.g. after a crash mid-processing.
+	lease = 5 * time.Minute
+	// Retention is how long processed attempts are remembered.
+	Retention = 7 * 24 * time.Hour
+)
+
+type deduplicationStore struct {
+	deduplicationRepository interfaces.DeduplicationRepository
+}
+
+// key identifies one attempt of one action: request, research, action, chunk,
+// voter and the dispatch the LLM answer belongs to. Replays and re-runs
+// dispatch again with the receive count they start from, so the receive count
+// only tells the attempts apart for callbacks sent without a dispatch id.
+func key(callback models.AiOrchestratorCallbackRequest) string {
+	parts := []string{*callback.RequestId}
+	if callback.ResearchId != nil {
+		parts = append(parts, *callback.ResearchId)
+	}
+	parts = append(parts, *callback.Action)
+	if callback.Chunk != nil {
+		parts = append(parts, fmt.Sprint(*callback.Chunk))
+	}
+	if callback.Voter != nil {
+		parts = append(parts, fmt.Sprint("voter-", *callback.Voter))
+	}
+	if callback.DispatchId != nil {
+		parts = append(parts, *callback.DispatchId)
+	} else {
+		parts = append(parts, fmt.Sprint(callback.ReceiveCount))
+	}
+	return strings.Join(parts, ":")
+}
+
+func (d deduplicationStore) Claim(ctx context.Context, callback models.AiOrchestratorCallbackRequest) (bool, error) {
+	id := key(callback)
+	status := statusProcessing
+	now := time.Now().UTC()
+
+	err := d.deduplicationRepository.Create(ctx, &models.Delivery{
+		ID:        &id,
+		Status:    &status,
+		ClaimedAt: &now,
+	})
+	if err == nil {
+		return true, nil
+	}
+	if !mongo.IsDuplicateKeyError(err) {
+		return false, err
+	}
+
+	return d.deduplicationRepository.UpdateOne(ctx,
+		bson.M{"_id": id, "status": statusProcessing, "claimedAt": bson.M{"$lt": now.Add(-lease)}},
+		bson.M{"$set": bson.M{"claimedAt": now}},
+	)
+}
+
+func (d deduplicationStore) Complete(ctx context.Context, callback models.AiOrchestratorCallbackRequest) error {
+	return d.deduplicationRepository.Update(ctx, key(callback), bson.M{"status": statusDone})
+}
+
+func (d deduplicationStore) Release(ctx context.Context, callback models.AiOrchestratorCallbackRequest) error {
+	return d.deduplicationRepository.Delete(ctx, key(callback))
+}
+
+func NewDeduplicationStore(deduplicationRepository interfaces.DeduplicationRepository) interfaces.DeduplicationStore {
+	return &deduplicationStore{
+		deduplicationRepository: deduplicationRepository,
+	}
+}
@@ -0,0 +1,139 @@
+package dispatcher
+
+import (
+	"context"
+	"errors"
+	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/interfaces"
+	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/models"
+	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/pipeline"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// promptDispatcher sends the questions to the llm client and keeps every
+// outstanding prompt recorded with the deadline of its stage, so the sweeper
+// can find the ones whose answer never came back.
+type promptDispatcher struct {
+	llmClient        interfaces.LLMClient
+	promptRepository interfaces.PromptRepository
+	usageRecorder    interfaces.UsageRecorder
+	pipeline         pipeline.Pipeline
+}
+
+// PromptId identifies the outstanding prompt of an action. Researches have one
+// prompt per action, requests one per action for the whole request, chunked
+// actions one per chunk and voted actions one per voter.
+func PromptId(requestId string, researchId *string, action string, chunk, voter *int) string {
+	parts := []string{requestId}
+	if researchId != nil {
+		parts = append(parts, *researchId)
+	}
+	parts = append(parts, action)
+	if chunk != nil {
+		parts = append(parts, strconv.Itoa(*chunk))
+	}
+	if voter != nil {
+		parts = append(parts, "voter-"+strconv.Itoa(*voter))
+	}
+	return strings.Join(parts, ":")
+}
+
+// Dispatch records the prompt and sends it. Every dispatch gets a new dispatch
+// id, unless the prompt already holds the one it was claimed with.
+func (d promptDispatcher) Dispatch(ctx context.Context, prompt models.Prompt) error {
+	slog.InfoContext(ctx, "promptDispatcher.Dispatch",
+		slog.String("details", "process started"),
+		slog.String("action", *prompt.Action),
+		slog.Int("receiveCount", prompt.ReceiveCount))
+
+	now := time.Now().UTC()
+	dispatchId := primitive.NewObjectID().Hex()
+	if prompt.DispatchId != nil {
+		dispatchId = *prompt.DispatchId
+	}
+	err := d.promptRepository.Upsert(ctx, PromptId(*prompt.RequestId, prompt.ResearchId, *prompt.Action, prompt.Chunk, prompt.Voter), bson.M{
+		"requestId":       prompt.RequestId,
+		"researchId":      prompt.ResearchId,
+		"action":          prompt.Action,
+		"question":        prompt.Question,
+		"templateVersion": prompt.TemplateVersion,
+		"chunk":           prompt.Chunk,
+		"voter":           prompt.Voter,
+		"receiveCount":    prompt.ReceiveCount,
+		"dispatchId":      dispatchId,
+		"deadline":        now.Add(d.pipeline.Deadline(*prompt.Action)),
+		"dispatchedAt":    now,
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "promptDispatcher.Dispatch",
+			slog.String("details", "process error"),
+			slog.String("error", err.Error()))
+		return err
+	}
+
+	prompt.DispatchId = &dispatchId
+	prompt.DispatchedAt = &now
+	err = d.llmClient.Send(ctx, prompt)
+	if err != nil {
+		slog.ErrorContext(ctx, "promptDispatcher.Dispatch",
+			slog.String("details", "process error"),
+			slog.String("error", err.Error()))
+		return err
+	}
+
+	err = d.usageRecorder.Prompt(ctx, prompt)
+	if err != nil {
+		slog.WarnContext(ctx, "promptDispatcher.Dispatch",
+			slog.String("details", "could not record usage"),
+			slog.String("error", err.Error()))
+	}
+
+	return nil
+}
+
+func (d promptDispatcher) Resolve(ctx context.Context, requestId string, researchId *string, action string, chunk, voter *int) error {
+	return d.promptRepository.Delete(ctx, PromptId(requestId, researchId, action, chunk, voter))
+}
+
+// Awaits tells whether the callback answers the dispatch its prompt still
+// waits for. Callbacks of a resolved prompt, or of a dispatch the sweeper
+// replaced, are late answers. Callbacks sent before dispatch ids existed are
+// always awaited while their prompt is outstanding.
+func (d promptDispatcher) Awaits(ctx context.Context, callback models.AiOrchestratorCallbackRequest) (bool, error) {
+	var prompt models.Prompt
+	err := d.promptRepository.GetById(ctx, PromptId(*callback.RequestId, callback.ResearchId, *callback.Action, callback.Chunk, callback.Voter), &prompt)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if callback.DispatchId == nil || prompt.DispatchId == nil {
+		return true, nil
+	}
+	return *callback.DispatchId == *prompt.DispatchId, nil
+}
+
+func (d promptDispatcher) Outstanding(ctx context.Context, requestId string, researchId *string, action string, chunk, voter *int) (bool, error) {
+	var prompt models.Prompt
+	err := d.promptRepository.GetById(ctx, PromptId(requestId, researchId, action, chunk, voter), &prompt)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func NewPromptDispatcher(llmClient interfaces.LLMClient, promptRepository interfaces.PromptRepository, usageRecorder interfaces.UsageRecorder, pipeline pipeline.Pipeline) interfaces.PromptDispatcher {
+	return &promptDispatcher{
+		llmClient:        llmClient,
+		promptRepository: promptRepository,
+		usageRecorder:    usageRecorder,
+		pipeline:         pipeline,
+	}
+}
@@ -7,4 +7,8 @@ const (
 	WorthAccessing = "worth-checking"
 	WorthSummarize = "worth-summarize"
 	Summarize      = "summarize"
+	SummarizeChunk = "summarize-chunk"
+	Overall        = "overall"
+	Cancel         = "cancel"
+	Replay         = "replay"
 )
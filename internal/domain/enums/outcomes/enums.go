@@ -0,0 +1,11 @@
+package enumoutcomes
+
+const (
+	Done     = "done"
+	Accepted = "accepted"
+	Rejected = "rejected"
+	// Voted is reported by the votes of an ensemble that do not decide it.
+	Voted = "voted"
+	// Failed is reported when every voter of an ensemble abstained.
+	Failed = "failed"
+)
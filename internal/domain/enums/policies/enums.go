@@ -0,0 +1,7 @@
+package enumpolicies
+
+const (
+	Strict        = "strict"
+	FilterInvalid = "filter-invalid"
+	MinValidCount = "min-valid-count"
+)
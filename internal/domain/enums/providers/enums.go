@@ -0,0 +1,7 @@
+package enumproviders
+
+const (
+	Gemini = "gemini"
+	OpenAI = "openai"
+	Stub   = "stub"
+)
@@ -0,0 +1,6 @@
+package enumstatuses
+
+// Statuses reported to status-manager besides the ones of the database lib.
+const (
+	Cancelled = "CANCELLED"
+)
@@ -0,0 +1,7 @@
+package enumvoting
+
+const (
+	Majority  = "majority"
+	AnyYes    = "any-yes"
+	Unanimous = "unanimous"
+)
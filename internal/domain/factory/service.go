@@ -14,6 +14,10 @@ type ServiceFactory struct {
 	WorthAccessingService interfaces.Service
 	WorthSummarizeService interfaces.Service
 	SummarizeService      interfaces.Service
+	SummarizeChunkService interfaces.Service
+	OverallService        interfaces.Service
+	CancelService         interfaces.Service
+	ReplayService         interfaces.Service
 }
 
 func (sf ServiceFactory) Factory(action string) (interfaces.Service, error) {
@@ -30,6 +34,14 @@ func (sf ServiceFactory) Factory(action string) (interfaces.Service, error) {
 		return sf.WorthSummarizeService, nil
 	case enumactions.Summarize:
 		return sf.SummarizeService, nil
+	case enumactions.SummarizeChunk:
+		return sf.SummarizeChunkService, nil
+	case enumactions.Overall:
+		return sf.OverallService, nil
+	case enumactions.Cancel:
+		return sf.CancelService, nil
+	case enumactions.Replay:
+		return sf.ReplayService, nil
 	}
 	return nil, errortypes.NewServiceNotFoundException(fmt.Sprintf("Service for action '%s' not found", action))
 }
@@ -0,0 +1,34 @@
+package guards
+
+import (
+	"context"
+	"errors"
+	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/interfaces"
+	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/models"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type cancellationGuard struct {
+	orchestratorRepository interfaces.OrchestratorRepository
+}
+
+// IsCancelled reports whether the request was cancelled. Requests that have no
+// orchestrator document yet can not have been cancelled.
+func (g cancellationGuard) IsCancelled(ctx context.Context, requestId string) (bool, error) {
+	var request models.OrchestratorRequest
+	err := g.orchestratorRepository.GetById(ctx, requestId, &request)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return request.CancelledAt != nil, nil
+}
+
+func NewCancellationGuard(orchestratorRepository interfaces.OrchestratorRepository) interfaces.CancellationGuard {
+	return &cancellationGuard{
+		orchestratorRepository: orchestratorRepository,
+	}
+}
@@ -0,0 +1,101 @@
+package injection
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+const (
+	// OpenMarker and CloseMarker surround the untrusted contents inside the
+	// prompts, which tell the model to treat what is between them as data.
+	OpenMarker  = "<<<UNTRUSTED_CONTENT"
+	CloseMarker = "UNTRUSTED_CONTENT>>>"
+
+	// EchoWords is how many consecutive words of the instructions an answer
+	// must repeat to be considered an echo of the prompt.
+	EchoWords = 8
+)
+
+var markers = regexp.MustCompile(`(?i)<<<\s*untrusted_content|untrusted_content\s*>>>`)
+
+// patterns are the instruction-like passages looked for in untrusted contents.
+var patterns = []struct {
+	name string
+	re   *regexp.Regexp
+}{
+	{"ignore-instructions", regexp.MustCompile(`(?i)\b(ignore|disregard|forget|override)\b.{0,40}\b(previous|prior|above|earlier|all|any|your)\b.{0,20}\b(instructions?|prompts?|rules|directions)\b`)},
+	{"new-instructions", regexp.MustCompile(`(?i)\bnew (instructions?|task|rules)\b`)},
+	{"role-override", regexp.MustCompile(`(?i)\byou are (now|no longer)\b|\bpretend (to be|you are)\b|\bact as (an?|the)\b`)},
+	{"system-prompt", regexp.MustCompile(`(?i)\bsystem prompt\b|(^|\n)\s*(system|assistant)\s*:`)},
+	{"answer-steering", regexp.MustCompile(`(?i)\b(respond|answer|reply|output|rate|score)\b.{0,30}\b(only with|with only|exactly|100|yes|true)\b`)},
+	{"answer-injection", regexp.MustCompile(`(?i)"(worth|score|reason|summary|report)"\s*:`)},
+	{"fence-escape", markers},
+}
+
+// Inspect lists the names of the instruction-like patterns found in an
+// untrusted content.
+func Inspect(content string) []string {
+	var found []string
+	for _, p := range patterns {
+		if p.re.MatchString(content) {
+			found = append(found, p.name)
+		}
+	}
+	return found
+}
+
+// Sanitize removes the control characters, other than line breaks and tabs,
+// and the fence markers from an untrusted content.
+func Sanitize(content string) string {
+	content = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) && r != '\n' && r != '\t' {
+			return -1
+		}
+		return r
+	}, content)
+
+	// Removing a marker may join the text around it into a new one, so the
+	// markers are removed until none is left.
+	for markers.MatchString(content) {
+		content = markers.ReplaceAllString(content, "")
+	}
+	return content
+}
+
+// Fence sanitizes an untrusted content and surrounds it with the markers.
+func Fence(content string) string {
+	return OpenMarker + "\n" + strings.TrimSpace(Sanitize(content)) + "\n" + CloseMarker
+}
+
+func words(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// Echoes tells whether an answer leaks the prompt: it repeats EchoWords
+// consecutive words of the instructions or contains the fence markers.
+func Echoes(answer, instructions string) bool {
+	if markers.MatchString(answer) {
+		return true
+	}
+
+	instruction := words(instructions)
+	if len(instruction) < EchoWords {
+		return false
+	}
+
+	grams := make(map[string]struct{}, len(instruction))
+	for i := 0; i+EchoWords <= len(instruction); i++ {
+		grams[strings.Join(instruction[i:i+EchoWords], " ")] = struct{}{}
+	}
+
+	answered := words(answer)
+	for i := 0; i+EchoWords <= len(answered); i++ {
+		if _, ok := grams[strings.Join(answered[i:i+EchoWords], " ")]; ok {
+			return true
+		}
+	}
+	return false
+}
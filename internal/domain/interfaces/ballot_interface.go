@@ -0,0 +1,13 @@
+package interfaces
+
+import (
+	"context"
+	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/models"
+)
+
+type Ballot interface {
+	Voters() []string
+	Open(ctx context.Context, researchId string) error
+	Cast(ctx context.Context, researchId string, voter int, vote models.Vote) (decided bool, decision models.Decision, err error)
+	Abstain(ctx context.Context, researchId string, voter int) (decided bool, decision models.Decision, err error)
+}
@@ -0,0 +1,15 @@
+package interfaces
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"time"
+)
+
+type CacheRepository interface {
+	GetById(ctx context.Context, id string, model interface{}) error
+	Upsert(ctx context.Context, id string, values bson.M) error
+	Delete(ctx context.Context, id string) error
+	EnsureTTLIndex(ctx context.Context, field string, ttl time.Duration) error
+	Connect(database, collection string)
+}
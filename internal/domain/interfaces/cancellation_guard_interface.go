@@ -0,0 +1,9 @@
+package interfaces
+
+import (
+	"context"
+)
+
+type CancellationGuard interface {
+	IsCancelled(ctx context.Context, requestId string) (bool, error)
+}
@@ -0,0 +1,23 @@
+package interfaces
+
+import (
+	"context"
+	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/models"
+	nosqlmodels "github.com/PesquisAi/pesquisai-database-lib/nosql/models"
+)
+
+type ChunkSummarizer interface {
+	// Prepare tells whether the content of the research can be used by the
+	// waiting action. Content that fits a prompt is ready with no summaries.
+	// Larger content is ready once every chunk is summarized; until then the
+	// missing chunks are dispatched and the waiting action is started again
+	// once they are all summarized.
+	Prepare(ctx context.Context, request nosqlmodels.Request, research models.OrchestratorResearch, waiting string) (summaries []string, ready bool, err error)
+	// Summaries tells the summaries of the chunks of the research and whether
+	// its content is ready, the way Prepare does, without preparing anything.
+	Summaries(research models.OrchestratorResearch) (summaries []string, ready bool)
+	// Question renders the prompt of a chunk with the given template version,
+	// the active one when it is empty.
+	Question(request nosqlmodels.Request, research models.OrchestratorResearch, chunk int, templateVersion string) (question, version string, err error)
+	Complete(ctx context.Context, requestId, researchId string, chunk int, summary string) error
+}
@@ -0,0 +1,11 @@
+package interfaces
+
+import (
+	"context"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+type DeadLetterQueue interface {
+	Publish(ctx context.Context, delivery amqp.Delivery, code string, reasons ...string) error
+	Connect() (err error)
+}
@@ -0,0 +1,16 @@
+package interfaces
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"time"
+)
+
+type DeduplicationRepository interface {
+	Create(ctx context.Context, model interface{}) error
+	Update(ctx context.Context, id string, values bson.M) error
+	UpdateOne(ctx context.Context, filter bson.M, update bson.M) (bool, error)
+	Delete(ctx context.Context, id string) error
+	EnsureTTLIndex(ctx context.Context, field string, ttl time.Duration) error
+	Connect(database, collection string)
+}
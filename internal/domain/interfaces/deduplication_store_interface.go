@@ -0,0 +1,12 @@
+package interfaces
+
+import (
+	"context"
+	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/models"
+)
+
+type DeduplicationStore interface {
+	Claim(ctx context.Context, callback models.AiOrchestratorCallbackRequest) (bool, error)
+	Complete(ctx context.Context, callback models.AiOrchestratorCallbackRequest) error
+	Release(ctx context.Context, callback models.AiOrchestratorCallbackRequest) error
+}
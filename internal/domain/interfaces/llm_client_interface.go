@@ -0,0 +1,12 @@
+package interfaces
+
+import (
+	"context"
+	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/models"
+)
+
+// LLMClient sends a prompt to a language model. Answers are always delivered
+// asynchronously, as a message on the ai-orchestrator-callback queue.
+type LLMClient interface {
+	Send(ctx context.Context, prompt models.Prompt) error
+}
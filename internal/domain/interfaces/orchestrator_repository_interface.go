@@ -9,5 +9,10 @@ type OrchestratorRepository interface {
 	GetById(ctx context.Context, id string, model interface{}) error
 	Create(ctx context.Context, model interface{}) error
 	Update(ctx context.Context, id string, values bson.M) error
+	FindOneAndUpdate(ctx context.Context, id string, update bson.M, model interface{}) error
+	UpdateOne(ctx context.Context, filter bson.M, update bson.M) (bool, error)
+	UpdateOneAndGet(ctx context.Context, filter bson.M, update bson.M, model interface{}) (bool, error)
+	Upsert(ctx context.Context, id string, values bson.M) error
+	Find(ctx context.Context, filter bson.M, limit int64, models interface{}) error
 	Connect(database, collection string)
 }
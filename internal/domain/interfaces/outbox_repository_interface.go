@@ -0,0 +1,16 @@
+package interfaces
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"time"
+)
+
+type OutboxRepository interface {
+	Create(ctx context.Context, model interface{}) error
+	Update(ctx context.Context, id string, values bson.M) error
+	UpdateOne(ctx context.Context, filter bson.M, update bson.M) (bool, error)
+	Find(ctx context.Context, filter bson.M, limit int64, models interface{}) error
+	EnsureTTLIndex(ctx context.Context, field string, ttl time.Duration) error
+	Connect(database, collection string)
+}
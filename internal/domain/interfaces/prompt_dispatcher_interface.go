@@ -0,0 +1,13 @@
+package interfaces
+
+import (
+	"context"
+	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/models"
+)
+
+type PromptDispatcher interface {
+	Dispatch(ctx context.Context, prompt models.Prompt) error
+	Resolve(ctx context.Context, requestId string, researchId *string, action string, chunk, voter *int) error
+	Outstanding(ctx context.Context, requestId string, researchId *string, action string, chunk, voter *int) (bool, error)
+	Awaits(ctx context.Context, callback models.AiOrchestratorCallbackRequest) (bool, error)
+}
@@ -0,0 +1,7 @@
+package interfaces
+
+type PromptRegistry interface {
+	// Render executes the given version of the named template, the active one
+	// when version is empty, and returns the prompt along with the version used.
+	Render(name, version string, data any) (prompt string, used string, err error)
+}
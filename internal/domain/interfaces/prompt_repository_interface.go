@@ -0,0 +1,15 @@
+package interfaces
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+type PromptRepository interface {
+	GetById(ctx context.Context, id string, model interface{}) error
+	Upsert(ctx context.Context, id string, values bson.M) error
+	UpdateOne(ctx context.Context, filter bson.M, update bson.M) (bool, error)
+	Find(ctx context.Context, filter bson.M, limit int64, models interface{}) error
+	Delete(ctx context.Context, id string) error
+	Connect(database, collection string)
+}
@@ -0,0 +1,9 @@
+package interfaces
+
+import (
+	"context"
+)
+
+type Relay interface {
+	Run(ctx context.Context) error
+}
@@ -0,0 +1,24 @@
+package interfaces
+
+import (
+	"context"
+	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/models"
+)
+
+type ResearchTracker interface {
+	Finish(ctx context.Context, requestId, researchId string) error
+	// Fail finishes a research as failed, giving back the source it held.
+	Fail(ctx context.Context, requestId, researchId string) error
+	// HandOff starts waiting for the content of a research sent to the
+	// web-scraper.
+	HandOff(ctx context.Context, researchId string) error
+	// Resume stops waiting for the content of a research, telling false when
+	// the research already expired and must be dropped.
+	Resume(ctx context.Context, research models.OrchestratorResearch) (bool, error)
+	// Expire finishes as failed the researches whose content did not come
+	// back in time.
+	Expire(ctx context.Context) error
+	// Settle completes the requests whose researches ended before the total
+	// of researches was known.
+	Settle(ctx context.Context) error
+}
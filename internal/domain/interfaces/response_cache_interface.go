@@ -0,0 +1,12 @@
+package interfaces
+
+import (
+	"context"
+	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/models"
+)
+
+type ResponseCache interface {
+	Lookup(ctx context.Context, prompt models.Prompt) (response string, hit bool, err error)
+	Store(ctx context.Context, callback models.AiOrchestratorCallbackRequest) error
+	Evict(ctx context.Context, callback models.AiOrchestratorCallbackRequest) error
+}
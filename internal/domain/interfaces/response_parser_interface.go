@@ -0,0 +1,9 @@
+package interfaces
+
+type ResponseParser interface {
+	// Schema is the JSON schema the answer of the action must follow.
+	Schema(action string) (string, error)
+	// Parse validates the answer against the schema of the action and decodes
+	// it into v, returning what is wrong with it when invalid.
+	Parse(action, response string, v any) []string
+}
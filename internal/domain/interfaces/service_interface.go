@@ -7,5 +7,5 @@ import (
 
 type Service interface {
 	Execute(ctx context.Context, request models.AiOrchestratorRequest) error
-	Callback(ctx context.Context, request models.AiOrchestratorCallbackRequest) error
+	Callback(ctx context.Context, request models.AiOrchestratorCallbackRequest) (outcome string, err error)
 }
@@ -0,0 +1,9 @@
+package interfaces
+
+import (
+	"context"
+)
+
+type Sweeper interface {
+	Run(ctx context.Context) error
+}
@@ -0,0 +1,9 @@
+package interfaces
+
+import (
+	"context"
+)
+
+type Transactor interface {
+	WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error
+}
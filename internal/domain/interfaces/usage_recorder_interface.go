@@ -0,0 +1,11 @@
+package interfaces
+
+import (
+	"context"
+	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/models"
+)
+
+type UsageRecorder interface {
+	Prompt(ctx context.Context, prompt models.Prompt) error
+	Response(ctx context.Context, callback models.AiOrchestratorCallbackRequest) error
+}
@@ -0,0 +1,8 @@
+package interfaces
+
+import "context"
+
+type UsageRepository interface {
+	Create(ctx context.Context, model interface{}) error
+	Connect(database, collection string)
+}
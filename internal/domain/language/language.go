@@ -0,0 +1,76 @@
+package language
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Default is the language assumed when a text gives no hint of its own.
+const Default = "en"
+
+// stopwords are frequent words telling the languages apart, used to guess the
+// language a request was written in.
+var stopwords = map[string][]string{
+	"pt": {"de", "que", "não", "nao", "para", "com", "uma", "os", "no", "na", "em", "do", "da", "dos", "das", "meu", "minha", "muito", "estou", "preciso", "quero", "são", "também", "você", "pesquisa", "sobre", "mais", "pelo", "pela", "até", "onde", "porque", "é"},
+	"en": {"the", "and", "of", "to", "in", "is", "that", "for", "with", "my", "we", "our", "are", "on", "about", "want", "need", "which", "this", "what", "how", "from", "be", "have"},
+	"es": {"de", "que", "el", "los", "las", "para", "con", "una", "en", "por", "del", "mi", "muy", "estoy", "necesito", "quiero", "son", "también", "usted", "sobre", "más", "donde", "porque", "es", "y"},
+	"fr": {"le", "la", "les", "de", "des", "et", "un", "une", "pour", "avec", "dans", "est", "que", "qui", "mon", "ma", "nous", "sur", "je", "veux", "besoin", "pas", "du", "au"},
+	"de": {"der", "die", "das", "und", "ist", "nicht", "ein", "eine", "mit", "für", "auf", "ich", "wir", "mein", "meine", "zu", "von", "den", "dem", "über", "möchte", "brauche", "sind"},
+	"it": {"il", "lo", "la", "gli", "le", "di", "che", "per", "con", "una", "un", "è", "sono", "mio", "mia", "voglio", "ho", "bisogno", "del", "della", "nel", "sulla", "anche"},
+}
+
+// order breaks the ties between languages, favouring the ones the product is
+// most used in.
+var order = []string{"pt", "en", "es", "fr", "de", "it"}
+
+var names = map[string]string{
+	"pt": "Portuguese",
+	"en": "English",
+	"es": "Spanish",
+	"fr": "French",
+	"de": "German",
+	"it": "Italian",
+	"nl": "Dutch",
+	"ja": "Japanese",
+	"zh": "Chinese",
+	"ko": "Korean",
+	"ru": "Russian",
+	"ar": "Arabic",
+	"hi": "Hindi",
+}
+
+// Detect guesses the language of a text from its stopwords, returning its two
+// letter code, or Default when no stopword is found.
+func Detect(text string) string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r)
+	})
+
+	scores := make(map[string]int, len(stopwords))
+	for _, word := range words {
+		for code, list := range stopwords {
+			for _, stopword := range list {
+				if word == stopword {
+					scores[code]++
+					break
+				}
+			}
+		}
+	}
+
+	best, bestScore := Default, 0
+	for _, code := range order {
+		if scores[code] > bestScore {
+			best, bestScore = code, scores[code]
+		}
+	}
+	return best
+}
+
+// Name is the english name of a language code, the code itself when unknown.
+func Name(code string) string {
+	if name, ok := names[strings.ToLower(code)]; ok {
+		return name
+	}
+	return code
+}
@@ -0,0 +1,48 @@
+package language
+
+import "testing"
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{"portuguese", "Quero uma pesquisa sobre o mercado de energia solar no Brasil", "pt"},
+		{"english", "I want to know about the market of solar panels in the US", "en"},
+		{"spanish", "Necesito un estudio sobre el mercado de los paneles solares", "es"},
+		{"french", "Je veux une étude sur le marché des panneaux solaires", "fr"},
+		{"german", "Ich brauche eine Studie über den Markt für Solarmodule", "de"},
+		{"italian", "Voglio uno studio sul mercato dei pannelli solari per la mia azienda", "it"},
+		{"case and punctuation", "QUERO, UMA PESQUISA!", "pt"},
+		{"tie favours the first in order", "de", "pt"},
+		{"no stopword", "solar panels", Default},
+		{"empty", "", Default},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Detect(tt.text)
+			if got != tt.want {
+				t.Errorf("Detect(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestName(t *testing.T) {
+	tests := []struct {
+		code string
+		want string
+	}{
+		{"pt", "Portuguese"},
+		{"EN", "English"},
+		{"xx", "xx"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.code, func(t *testing.T) {
+			if got := Name(tt.code); got != tt.want {
+				t.Errorf("Name(%q) = %q, want %q", tt.code, got, tt.want)
+			}
+		})
+	}
+}
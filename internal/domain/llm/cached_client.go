@@ -0,0 +1,56 @@
+package llm
+
+import (
+	"context"
+	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/builder"
+	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/interfaces"
+	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/models"
+	"log/slog"
+)
+
+// cachedClient answers the prompts already answered before from the response
+// cache. Only the prompts missing from the cache reach the wrapped client.
+//
+// A hit is not handed to Service.Callback in place: the cached answer is
+// published to the callback queue, as the LLM worker would, and goes through
+// the callback consumer. It skips the provider round trip, while its callback
+// is still deduplicated, routed and committed in its own transaction, and the
+// prompt being dispatched is committed before its answer is processed.
+type cachedClient struct {
+	client        interfaces.LLMClient
+	responseCache interfaces.ResponseCache
+	queueCallback interfaces.Queue
+}
+
+func (c cachedClient) Send(ctx context.Context, prompt models.Prompt) error {
+	response, hit, err := c.responseCache.Lookup(ctx, prompt)
+	if err != nil {
+		slog.WarnContext(ctx, "cachedClient.Send",
+			slog.String("details", "could not look up response cache"),
+			slog.String("error", err.Error()))
+	}
+
+	if !hit {
+		return c.client.Send(ctx, prompt)
+	}
+
+	slog.InfoContext(ctx, "cachedClient.Send",
+		slog.String("details", "answering prompt from cache"),
+		slog.String("action", *prompt.Action))
+
+	prompt.Cached = true
+	b, err := builder.BuildQueueCallbackMessage(prompt, response)
+	if err != nil {
+		return err
+	}
+
+	return c.queueCallback.Publish(ctx, b)
+}
+
+func NewCachedClient(client interfaces.LLMClient, responseCache interfaces.ResponseCache, queueCallback interfaces.Queue) interfaces.LLMClient {
+	return &cachedClient{
+		client:        client,
+		responseCache: responseCache,
+		queueCallback: queueCallback,
+	}
+}
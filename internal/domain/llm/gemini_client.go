@@ -0,0 +1,30 @@
+package llm
+
+import (
+	"context"
+	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/config/properties"
+	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/builder"
+	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/interfaces"
+	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/models"
+)
+
+// geminiClient hands the prompt to the gemini worker, which answers on the
+// queue given as output_queue.
+type geminiClient struct {
+	queueGemini interfaces.Queue
+}
+
+func (c geminiClient) Send(ctx context.Context, prompt models.Prompt) error {
+	b, err := builder.BuildQueueGeminiMessage(prompt, properties.QueueNameAiOrchestratorCallback)
+	if err != nil {
+		return err
+	}
+
+	return c.queueGemini.Publish(ctx, b)
+}
+
+func NewGeminiClient(queueGemini interfaces.Queue) interfaces.LLMClient {
+	return &geminiClient{
+		queueGemini: queueGemini,
+	}
+}
@@ -0,0 +1,144 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/builder"
+	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/interfaces"
+	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/models"
+	"net/http"
+	"strings"
+)
+
+type chatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type chatCompletionRequest struct {
+	Model    string        `json:"model"`
+	Messages []chatMessage `json:"messages"`
+}
+
+type chatCompletionResponse struct {
+	Choices []struct {
+		Message chatMessage `json:"message"`
+	} `json:"choices"`
+}
+
+// openAIClient records the prompts for the openai worker in the outbox. The
+// chat completions call is made by the worker once the transaction dispatching
+// the prompt is committed, so it is neither repeated when the transaction is
+// retried nor kept open while the endpoint answers.
+type openAIClient struct {
+	queuePrompts interfaces.Queue
+}
+
+func (c openAIClient) Send(ctx context.Context, prompt models.Prompt) error {
+	b, err := json.Marshal(prompt)
+	if err != nil {
+		return err
+	}
+
+	return c.queuePrompts.Publish(ctx, b)
+}
+
+// openAIWorker calls an OpenAI compatible chat completions endpoint with the
+// prompts recorded by the openai client and publishes the answers to the
+// callback queue, the same way the gemini worker does. It is handed the
+// prompts by an outbox relay, as the queue of their outbox messages.
+type openAIWorker struct {
+	httpClient    *http.Client
+	baseURL       string
+	apiKey        string
+	model         string
+	queueCallback interfaces.Queue
+}
+
+func (c openAIWorker) complete(ctx context.Context, question string) (string, error) {
+	body, err := json.Marshal(chatCompletionRequest{
+		Model:    c.model,
+		Messages: []chatMessage{{Role: "user", Content: question}},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost,
+		strings.TrimSuffix(c.baseURL, "/")+"/chat/completions", bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("chat completions answered with status %d", res.StatusCode)
+	}
+
+	var completion chatCompletionResponse
+	err = json.NewDecoder(res.Body).Decode(&completion)
+	if err != nil {
+		return "", err
+	}
+	if len(completion.Choices) == 0 {
+		return "", errors.New("chat completions answered without choices")
+	}
+
+	return completion.Choices[0].Message.Content, nil
+}
+
+func (c openAIWorker) Publish(ctx context.Context, b []byte) error {
+	var prompt models.Prompt
+	err := json.Unmarshal(b, &prompt)
+	if err != nil {
+		return err
+	}
+
+	response, err := c.complete(ctx, *prompt.Question)
+	if err != nil {
+		return err
+	}
+
+	b, err = builder.BuildQueueCallbackMessage(prompt, response)
+	if err != nil {
+		return err
+	}
+
+	return c.queueCallback.Publish(ctx, b)
+}
+
+func (c openAIWorker) Connect() error {
+	return nil
+}
+
+func (c openAIWorker) Close() error {
+	return nil
+}
+
+func NewOpenAIClient(queuePrompts interfaces.Queue) interfaces.LLMClient {
+	return &openAIClient{
+		queuePrompts: queuePrompts,
+	}
+}
+
+func NewOpenAIWorker(httpClient *http.Client, baseURL, apiKey, model string, queueCallback interfaces.Queue) interfaces.Queue {
+	return &openAIWorker{
+		httpClient:    httpClient,
+		baseURL:       baseURL,
+		apiKey:        apiKey,
+		model:         model,
+		queueCallback: queueCallback,
+	}
+}
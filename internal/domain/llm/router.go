@@ -0,0 +1,43 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/interfaces"
+	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/models"
+	"log/slog"
+)
+
+// router sends each prompt to the client of the provider configured for its
+// action, or to the provider of its voter when the action is voted.
+type router struct {
+	clients  map[string]interfaces.LLMClient
+	provider func(action string) string
+	voters   func(action string) []string
+}
+
+func (r router) Send(ctx context.Context, prompt models.Prompt) error {
+	provider := r.provider(*prompt.Action)
+	if prompt.Voter != nil {
+		if voters := r.voters(*prompt.Action); *prompt.Voter < len(voters) {
+			provider = voters[*prompt.Voter]
+		}
+	}
+	client, ok := r.clients[provider]
+	if !ok {
+		return fmt.Errorf("unknown llm provider '%s' for action '%s'", provider, *prompt.Action)
+	}
+
+	slog.DebugContext(ctx, "router.Send",
+		slog.String("action", *prompt.Action),
+		slog.String("provider", provider))
+	return client.Send(ctx, prompt)
+}
+
+func NewRouter(clients map[string]interfaces.LLMClient, provider func(action string) string, voters func(action string) []string) interfaces.LLMClient {
+	return &router{
+		clients:  clients,
+		provider: provider,
+		voters:   voters,
+	}
+}
@@ -0,0 +1,90 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/builder"
+	enumactions "github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/enums/actions"
+	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/interfaces"
+	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/models"
+	"regexp"
+	"strconv"
+)
+
+// stubSentenceCount is the amount of sentences answered to a question that
+// does not tell how many it wants.
+const stubSentenceCount = 5
+
+// sentenceAmount finds the amount of sentences a sentences question asks for.
+var sentenceAmount = regexp.MustCompile(`exactly (\d+) different sentences`)
+
+// StubResponses are the deterministic answers of the stub client, valid for
+// every stage of the default pipeline. The sentences are answered by
+// StubResponse, as many as the question asks for.
+var StubResponses = map[string]string{
+	enumactions.Location:       `{"locations":["br"]}`,
+	enumactions.Language:       `{"languages":["pt"]}`,
+	enumactions.WorthAccessing: `{"score":80,"reason":"Stub reason."}`,
+	enumactions.WorthSummarize: `{"score":80,"reason":"Stub reason."}`,
+	enumactions.Summarize:      `{"summary":"Stub summary of the web page."}`,
+	enumactions.SummarizeChunk: `{"summary":"Stub summary of a part of the web page."}`,
+	enumactions.Overall:        `{"report":"Stub report of the research [1]."}`,
+}
+
+type stubSentence struct {
+	Sentence string `json:"sentence"`
+	Language string `json:"language"`
+	Country  string `json:"country"`
+}
+
+// StubResponse is the deterministic answer of the stub client to a question of
+// the action.
+func StubResponse(action, question string) (string, bool) {
+	if action != enumactions.Sentences {
+		response, ok := StubResponses[action]
+		return response, ok
+	}
+
+	count := stubSentenceCount
+	if match := sentenceAmount.FindStringSubmatch(question); match != nil {
+		count, _ = strconv.Atoi(match[1])
+	}
+
+	sentences := make([]stubSentence, count)
+	for i := range sentences {
+		sentences[i] = stubSentence{
+			Sentence: fmt.Sprintf("stub sentence %d", i+1),
+			Language: "pt",
+			Country:  "br",
+		}
+	}
+
+	b, _ := json.Marshal(map[string][]stubSentence{"sentences": sentences})
+	return string(b), true
+}
+
+// stubClient answers every prompt locally, without reaching any model.
+type stubClient struct {
+	queueCallback interfaces.Queue
+}
+
+func (c stubClient) Send(ctx context.Context, prompt models.Prompt) error {
+	response, ok := StubResponse(*prompt.Action, *prompt.Question)
+	if !ok {
+		return fmt.Errorf("stub has no response for action '%s'", *prompt.Action)
+	}
+
+	b, err := builder.BuildQueueCallbackMessage(prompt, response)
+	if err != nil {
+		return err
+	}
+
+	return c.queueCallback.Publish(ctx, b)
+}
+
+func NewStubClient(queueCallback interfaces.Queue) interfaces.LLMClient {
+	return &stubClient{
+		queueCallback: queueCallback,
+	}
+}
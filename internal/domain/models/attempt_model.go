@@ -0,0 +1,18 @@
+package models
+
+import (
+	"time"
+)
+
+// Attempt is an LLM answer rejected by validation, kept on the orchestrator
+// document of the request.
+type Attempt struct {
+	Action       *string    `bson:"action,omitempty"`
+	ResearchId   *string    `bson:"researchId,omitempty"`
+	Chunk        *int       `bson:"chunk,omitempty"`
+	Voter        *int       `bson:"voter,omitempty"`
+	ReceiveCount int        `bson:"receiveCount"`
+	Answer       *string    `bson:"answer,omitempty"`
+	Messages     []string   `bson:"messages,omitempty"`
+	CreatedAt    *time.Time `bson:"createdAt,omitempty"`
+}
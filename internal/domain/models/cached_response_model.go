@@ -0,0 +1,15 @@
+package models
+
+import (
+	"time"
+)
+
+// CachedResponse is an accepted LLM answer, kept under the hash of the prompt
+// that produced it.
+type CachedResponse struct {
+	ID              *string    `bson:"_id,omitempty"`
+	Action          *string    `bson:"action,omitempty"`
+	TemplateVersion *string    `bson:"templateVersion,omitempty"`
+	Response        *string    `bson:"response,omitempty"`
+	CreatedAt       *time.Time `bson:"createdAt,omitempty"`
+}
@@ -1,9 +1,24 @@
 package models
 
+import (
+	"time"
+)
+
 type AiOrchestratorCallbackRequest struct {
 	RequestId    *string
 	ResearchId   *string
 	Response     *string
 	Action       *string
+	Chunk        *int
+	Voter        *int
 	ReceiveCount int
+	DispatchId   *string
+	DispatchedAt *time.Time
+	Cached       bool
+	// TemplateVersion is the version of the prompt template answered, which
+	// the retry of an invalid answer is rendered with.
+	TemplateVersion *string
+	// Abstained is set by the sweeper on the prompt of a voter that was not
+	// answered within MAX_AI_RECEIVE_COUNT attempts. It has no response.
+	Abstained bool
 }
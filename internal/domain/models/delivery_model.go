@@ -0,0 +1,13 @@
+package models
+
+import (
+	"time"
+)
+
+// Delivery marks a callback attempt as being processed, or already processed,
+// so redelivered messages can be told apart from new ones.
+type Delivery struct {
+	ID        *string    `bson:"_id,omitempty"`
+	Status    *string    `bson:"status,omitempty"`
+	ClaimedAt *time.Time `bson:"claimedAt,omitempty"`
+}
@@ -0,0 +1,10 @@
+package models
+
+// Options tune how a request is researched, kept on its orchestrator document.
+type Options struct {
+	SentenceCount *int            `bson:"sentenceCount,omitempty"`
+	MaxSources    *int            `bson:"maxSources,omitempty"`
+	SummaryLength *string         `bson:"summaryLength,omitempty"`
+	SummaryStyle  *string         `bson:"summaryStyle,omitempty"`
+	Stages        map[string]bool `bson:"stages,omitempty"`
+}
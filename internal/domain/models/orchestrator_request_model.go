@@ -0,0 +1,28 @@
+package models
+
+import (
+	nosqlmodels "github.com/PesquisAi/pesquisai-database-lib/nosql/models"
+	"time"
+)
+
+// OrchestratorRequest is the orchestrator document of a request. It extends the
+// shared nosql model with the fields maintained only by the orchestrator.
+type OrchestratorRequest struct {
+	nosqlmodels.Request `bson:",inline"`
+	Overall             *string    `bson:"overall,omitempty"`
+	FinishedResearches  []string   `bson:"finishedResearches,omitempty"`
+	TotalResearches     *int       `bson:"totalResearches,omitempty"`
+	CompletedAt         *time.Time `bson:"completedAt,omitempty"`
+	CancelledAt         *time.Time `bson:"cancelledAt,omitempty"`
+	Attempts            []Attempt  `bson:"attempts,omitempty"`
+	Usage               *Usage     `bson:"usage,omitempty"`
+	// OutputLanguage is the language code summaries and reports are written in.
+	OutputLanguage *string  `bson:"outputLanguage,omitempty"`
+	Options        *Options `bson:"options,omitempty"`
+	// SummarizedResearches are the researches holding one of the sources the
+	// request may summarize, when its options limit them.
+	SummarizedResearches []string `bson:"summarizedResearches,omitempty"`
+	// Thresholds are the relevance scores, by action, a research must reach
+	// to go further.
+	Thresholds map[string]int `bson:"thresholds,omitempty"`
+}
@@ -0,0 +1,80 @@
+package models
+
+import (
+	nosqlmodels "github.com/PesquisAi/pesquisai-database-lib/nosql/models"
+	"time"
+)
+
+// OrchestratorResearch is the orchestrator document of a research. It extends
+// the shared nosql model with the fields maintained only by the orchestrator.
+type OrchestratorResearch struct {
+	nosqlmodels.Research `bson:",inline"`
+	Chunks               *Chunks                    `bson:"chunks,omitempty"`
+	Votes                map[string]map[string]Vote `bson:"votes,omitempty"`
+	Decisions            map[string]Decision        `bson:"decisions,omitempty"`
+	Relevance            map[string]Relevance       `bson:"relevance,omitempty"`
+	Suspicious           *Suspicion                 `bson:"suspicious,omitempty"`
+	Handoff              *Handoff                   `bson:"handoff,omitempty"`
+	// Abstentions are the voters, keyed by action and voter index, that did
+	// not answer within MAX_AI_RECEIVE_COUNT attempts.
+	Abstentions map[string]map[string]time.Time `bson:"abstentions,omitempty"`
+}
+
+// Chunks tracks the summarization of the chunks of a content too large for a
+// single prompt. Summaries are keyed by chunk index. Budget is the token budget
+// the content was split with, so the chunks stay the same when it changes.
+type Chunks struct {
+	Total     int               `bson:"total"`
+	Budget    int               `bson:"budget,omitempty"`
+	Summaries map[string]string `bson:"summaries,omitempty"`
+	Waiting   []string          `bson:"waiting,omitempty"`
+	StartedAt *time.Time        `bson:"startedAt,omitempty"`
+	MappedAt  *time.Time        `bson:"mappedAt,omitempty"`
+}
+
+// Handoff tracks a research handed to the web-scraper, which does not report
+// its failures back: the research is finished as failed once Deadline passes
+// without its content.
+type Handoff struct {
+	Deadline  *time.Time `bson:"deadline,omitempty"`
+	ExpiredAt *time.Time `bson:"expiredAt,omitempty"`
+}
+
+// Vote is the answer of one voter of an ensemble to a yes/no decision. Votes
+// are keyed by action, then by voter index.
+type Vote struct {
+	Provider  *string    `bson:"provider,omitempty"`
+	Score     int        `bson:"score"`
+	Reason    *string    `bson:"reason,omitempty"`
+	Worth     bool       `bson:"worth"`
+	CreatedAt *time.Time `bson:"createdAt,omitempty"`
+}
+
+// Decision is the outcome of the votes of an ensemble on an action. Score is
+// the mean score of the votes.
+type Decision struct {
+	Worth     bool       `bson:"worth"`
+	Score     int        `bson:"score"`
+	Rule      string     `bson:"rule,omitempty"`
+	Yes       int        `bson:"yes"`
+	No        int        `bson:"no"`
+	Abstained int        `bson:"abstained,omitempty"`
+	DecidedAt *time.Time `bson:"decidedAt,omitempty"`
+}
+
+// Relevance is the score an action gave to a research, keyed by action, and
+// whether it reached the threshold of the request.
+type Relevance struct {
+	Score     int        `bson:"score"`
+	Reason    *string    `bson:"reason,omitempty"`
+	Threshold int        `bson:"threshold"`
+	Worth     bool       `bson:"worth"`
+	CreatedAt *time.Time `bson:"createdAt,omitempty"`
+}
+
+// Suspicion flags a research whose content holds instruction-like passages,
+// which may be an attempt to steer the prompts it is used in.
+type Suspicion struct {
+	Patterns  []string   `bson:"patterns,omitempty"`
+	FlaggedAt *time.Time `bson:"flaggedAt,omitempty"`
+}
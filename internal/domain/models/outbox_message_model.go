@@ -0,0 +1,21 @@
+package models
+
+import (
+	"time"
+)
+
+// OutboxMessage is a queue message written together with the state change that
+// produced it, waiting for the relay to publish it.
+type OutboxMessage struct {
+	ID        *string    `bson:"_id,omitempty"`
+	Queue     *string    `bson:"queue,omitempty"`
+	Body      []byte     `bson:"body,omitempty"`
+	Status    *string    `bson:"status,omitempty"`
+	CreatedAt *time.Time `bson:"createdAt,omitempty"`
+	ClaimedAt *time.Time `bson:"claimedAt,omitempty"`
+	SentAt    *time.Time `bson:"sentAt,omitempty"`
+	// Attempts is how many times a relay took the message to publish it.
+	Attempts int        `bson:"attempts,omitempty"`
+	Error    *string    `bson:"error,omitempty"`
+	FailedAt *time.Time `bson:"failedAt,omitempty"`
+}
@@ -0,0 +1,25 @@
+package models
+
+import (
+	"time"
+)
+
+// Prompt is a question sent to the LLM that still waits for its callback.
+type Prompt struct {
+	ID              *string    `bson:"_id,omitempty"`
+	RequestId       *string    `bson:"requestId,omitempty"`
+	ResearchId      *string    `bson:"researchId,omitempty"`
+	Action          *string    `bson:"action,omitempty"`
+	Question        *string    `bson:"question,omitempty"`
+	TemplateVersion *string    `bson:"templateVersion,omitempty"`
+	Chunk           *int       `bson:"chunk,omitempty"`
+	Voter           *int       `bson:"voter,omitempty"`
+	ReceiveCount    int        `bson:"receiveCount"`
+	Deadline        *time.Time `bson:"deadline,omitempty"`
+	DispatchedAt    *time.Time `bson:"dispatchedAt,omitempty"`
+	// DispatchId is unique to every dispatch of the prompt, so the callbacks
+	// of a replayed or re-run stage are told apart from the earlier ones.
+	DispatchId *string `bson:"dispatchId,omitempty"`
+	// Cached tells the prompt was answered from the response cache.
+	Cached bool `bson:"-"`
+}
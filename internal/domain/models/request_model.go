@@ -1,9 +1,13 @@
 package models
 
 type AiOrchestratorRequest struct {
-	RequestId  *string
-	ResearchId *string
-	Context    *string
-	Research   *string
-	Action     *string
+	RequestId      *string
+	ResearchId     *string
+	Context        *string
+	Research       *string
+	Action         *string
+	ReplayAction   *string
+	Thresholds     map[string]int
+	OutputLanguage *string
+	Options        *Options
 }
@@ -0,0 +1,9 @@
+package models
+
+// Sentence is a google search sentence with the language it is written in and
+// the country it searches.
+type Sentence struct {
+	Sentence string `bson:"sentence"`
+	Language string `bson:"language"`
+	Country  string `bson:"country"`
+}
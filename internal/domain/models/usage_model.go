@@ -0,0 +1,39 @@
+package models
+
+import (
+	"time"
+)
+
+// UsageRecord is one prompt sent to, or one answer received from, the LLM,
+// kept for billing and budgeting the request.
+type UsageRecord struct {
+	ID           *string    `bson:"_id,omitempty"`
+	RequestId    *string    `bson:"requestId,omitempty"`
+	ResearchId   *string    `bson:"researchId,omitempty"`
+	Action       *string    `bson:"action,omitempty"`
+	Chunk        *int       `bson:"chunk,omitempty"`
+	Voter        *int       `bson:"voter,omitempty"`
+	Kind         *string    `bson:"kind,omitempty"`
+	ReceiveCount int        `bson:"receiveCount"`
+	PromptSize   int        `bson:"promptSize,omitempty"`
+	ResponseSize int        `bson:"responseSize,omitempty"`
+	LatencyMs    int64      `bson:"latencyMs,omitempty"`
+	Cached       bool       `bson:"cached,omitempty"`
+	CreatedAt    *time.Time `bson:"createdAt,omitempty"`
+}
+
+// UsageTotals are the usage records of a request summed up.
+type UsageTotals struct {
+	Prompts         int64 `bson:"prompts"`
+	Retries         int64 `bson:"retries"`
+	PromptChars     int64 `bson:"promptChars"`
+	Responses       int64 `bson:"responses"`
+	CachedResponses int64 `bson:"cachedResponses"`
+	ResponseChars   int64 `bson:"responseChars"`
+	LatencyMs       int64 `bson:"latencyMs"`
+}
+
+type Usage struct {
+	UsageTotals `bson:",inline"`
+	Actions     map[string]UsageTotals `bson:"actions,omitempty"`
+}
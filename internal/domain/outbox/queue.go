@@ -0,0 +1,54 @@
+package outbox
+
+import (
+	"context"
+	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/interfaces"
+	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+const (
+	StatusPending = "pending"
+	StatusSending = "sending"
+	StatusSent    = "sent"
+	// StatusDead is a message its relay gave up publishing.
+	StatusDead = "dead"
+)
+
+// queue is an interfaces.Queue that, instead of publishing, stores the message
+// in the outbox. Published within a transaction context, the message is only
+// stored if the transaction commits.
+type queue struct {
+	name             string
+	outboxRepository interfaces.OutboxRepository
+}
+
+func (q queue) Publish(ctx context.Context, b []byte) error {
+	id := primitive.NewObjectID().Hex()
+	status := StatusPending
+	now := time.Now().UTC()
+
+	return q.outboxRepository.Create(ctx, &models.OutboxMessage{
+		ID:        &id,
+		Queue:     &q.name,
+		Body:      b,
+		Status:    &status,
+		CreatedAt: &now,
+	})
+}
+
+func (q queue) Connect() error {
+	return nil
+}
+
+func (q queue) Close() error {
+	return nil
+}
+
+func NewQueue(outboxRepository interfaces.OutboxRepository, name string) interfaces.Queue {
+	return &queue{
+		name:             name,
+		outboxRepository: outboxRepository,
+	}
+}
@@ -0,0 +1,148 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/interfaces"
+	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"log/slog"
+	"time"
+)
+
+const (
+	batchSize = 100
+	// Lease is how long a message being sent to a queue is held by a relay
+	// before another one may take it over, e.g. after a crash between publish
+	// and acknowledge.
+	Lease = time.Minute
+	// Retention is how long sent messages are kept in the outbox.
+	Retention = 24 * time.Hour
+)
+
+// relay publishes the pending outbox messages to their queues and marks them
+// as sent. Delivery is at least once: consumers must tolerate duplicates.
+// A relay only takes the messages of the queues it is given, so slow
+// destinations can be relayed apart with their own lease. A message that
+// still fails after maxRetries retries is marked dead, unless maxRetries is
+// 0 and it is retried until published.
+type relay struct {
+	outboxRepository interfaces.OutboxRepository
+	queues           map[string]interfaces.Queue
+	interval         time.Duration
+	lease            time.Duration
+	maxRetries       int
+}
+
+// bury marks as dead a message that failed too many times, keeping the error
+// of its last attempt.
+func (r relay) bury(ctx context.Context, message models.OutboxMessage, cause error) error {
+	slog.WarnContext(ctx, "relay.bury",
+		slog.String("details", "message failed too many times, marking it as dead"),
+		slog.String("message", *message.ID),
+		slog.Int("attempts", message.Attempts+1))
+
+	return errors.Join(cause, r.outboxRepository.Update(ctx, *message.ID, bson.M{
+		"status":   StatusDead,
+		"error":    cause.Error(),
+		"failedAt": time.Now().UTC(),
+	}))
+}
+
+func (r relay) send(ctx context.Context, message models.OutboxMessage, now time.Time) error {
+	claimed, err := r.outboxRepository.UpdateOne(ctx,
+		bson.M{"_id": *message.ID, "status": *message.Status, "claimedAt": message.ClaimedAt},
+		bson.M{
+			"$set": bson.M{"status": StatusSending, "claimedAt": now},
+			"$inc": bson.M{"attempts": 1},
+		},
+	)
+	if err != nil || !claimed {
+		return err
+	}
+
+	queue, ok := r.queues[*message.Queue]
+	if !ok {
+		return fmt.Errorf("outbox message routed to unknown queue '%s'", *message.Queue)
+	}
+
+	err = queue.Publish(ctx, message.Body)
+	if err != nil {
+		if r.maxRetries > 0 && message.Attempts >= r.maxRetries {
+			return r.bury(ctx, message, err)
+		}
+		return err
+	}
+
+	return r.outboxRepository.Update(ctx, *message.ID, bson.M{
+		"status": StatusSent,
+		"sentAt": time.Now().UTC(),
+	})
+}
+
+func (r relay) relay(ctx context.Context) error {
+	now := time.Now().UTC()
+
+	queues := make(bson.A, 0, len(r.queues))
+	for name := range r.queues {
+		queues = append(queues, name)
+	}
+
+	var messages []models.OutboxMessage
+	err := r.outboxRepository.Find(ctx, bson.M{
+		"queue": bson.M{"$in": queues},
+		"$or": bson.A{
+			bson.M{"status": StatusPending},
+			bson.M{"status": StatusSending, "claimedAt": bson.M{"$lt": now.Add(-r.lease)}},
+		},
+	}, batchSize, &messages)
+	if err != nil {
+		return err
+	}
+
+	for _, message := range messages {
+		err = r.send(ctx, message, now)
+		if err != nil {
+			slog.ErrorContext(ctx, "relay.relay",
+				slog.String("details", "process error"),
+				slog.String("message", *message.ID),
+				slog.String("error", err.Error()))
+		}
+	}
+	return nil
+}
+
+func (r relay) Run(ctx context.Context) error {
+	slog.InfoContext(ctx, "relay.Run",
+		slog.String("details", "process started"),
+		slog.Duration("interval", r.interval),
+		slog.Duration("lease", r.lease))
+
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			err := r.relay(ctx)
+			if err != nil {
+				slog.ErrorContext(ctx, "relay.Run",
+					slog.String("details", "process error"),
+					slog.String("error", err.Error()))
+			}
+		}
+	}
+}
+
+func NewRelay(outboxRepository interfaces.OutboxRepository, queues map[string]interfaces.Queue, interval, lease time.Duration, maxRetries int) interfaces.Relay {
+	return &relay{
+		outboxRepository: outboxRepository,
+		queues:           queues,
+		interval:         interval,
+		lease:            lease,
+		maxRetries:       maxRetries,
+	}
+}
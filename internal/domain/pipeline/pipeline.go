@@ -0,0 +1,102 @@
+package pipeline
+
+import (
+	"fmt"
+	enumactions "github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/enums/actions"
+	enumoutcomes "github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/enums/outcomes"
+	"time"
+)
+
+// DefaultDeadline is how long a stage prompt may wait for its LLM answer when
+// the stage does not declare its own deadline.
+const DefaultDeadline = 5 * time.Minute
+
+// Stage describes one orchestrator action and, for each outcome its callback
+// may report, the action that must run next. Outcomes without an entry end the
+// orchestrator side of the flow (e.g. hand-offs to google-search or web-scraper).
+type Stage struct {
+	Action   string
+	Next     map[string]string
+	Deadline time.Duration
+}
+
+type Pipeline struct {
+	Stages []Stage
+	// Completion is the action started once every research of a request has
+	// ended. It is left empty when nothing should run after the fan-in.
+	Completion string
+}
+
+// Default is the research flow executed by the orchestrator.
+var Default = Pipeline{
+	Stages: []Stage{
+		{Action: enumactions.Location, Deadline: 2 * time.Minute, Next: map[string]string{enumoutcomes.Done: enumactions.Language}},
+		{Action: enumactions.Language, Deadline: 2 * time.Minute, Next: map[string]string{enumoutcomes.Done: enumactions.Sentences}},
+		{Action: enumactions.Sentences, Deadline: 2 * time.Minute},
+		{Action: enumactions.WorthAccessing, Deadline: 2 * time.Minute},
+		{Action: enumactions.WorthSummarize, Deadline: 5 * time.Minute, Next: map[string]string{enumoutcomes.Accepted: enumactions.Summarize}},
+		{Action: enumactions.Summarize, Deadline: 5 * time.Minute},
+		{Action: enumactions.SummarizeChunk, Deadline: 5 * time.Minute},
+		{Action: enumactions.Overall, Deadline: 10 * time.Minute},
+	},
+	Completion: enumactions.Overall,
+}
+
+func (p Pipeline) stage(action string) (Stage, bool) {
+	for _, stage := range p.Stages {
+		if stage.Action == action {
+			return stage, true
+		}
+	}
+	return Stage{}, false
+}
+
+// Has reports whether the action is declared as a stage of the pipeline.
+func (p Pipeline) Has(action string) bool {
+	_, ok := p.stage(action)
+	return ok
+}
+
+// Next returns the action that follows the given action/outcome pair, if any.
+func (p Pipeline) Next(action, outcome string) (string, bool) {
+	stage, ok := p.stage(action)
+	if !ok {
+		return "", false
+	}
+	next, ok := stage.Next[outcome]
+	return next, ok
+}
+
+// Deadline returns how long a prompt of the given action may stay unanswered.
+func (p Pipeline) Deadline(action string) time.Duration {
+	stage, ok := p.stage(action)
+	if !ok || stage.Deadline <= 0 {
+		return DefaultDeadline
+	}
+	return stage.Deadline
+}
+
+// Validate checks that every stage is declared once and that every transition
+// targets a declared stage.
+func (p Pipeline) Validate() error {
+	declared := map[string]bool{}
+	for _, stage := range p.Stages {
+		if declared[stage.Action] {
+			return fmt.Errorf("pipeline stage '%s' declared more than once", stage.Action)
+		}
+		declared[stage.Action] = true
+	}
+
+	if p.Completion != "" && !declared[p.Completion] {
+		return fmt.Errorf("pipeline completion routes to undeclared stage '%s'", p.Completion)
+	}
+
+	for _, stage := range p.Stages {
+		for outcome, next := range stage.Next {
+			if !declared[next] {
+				return fmt.Errorf("pipeline stage '%s' routes '%s' to undeclared stage '%s'", stage.Action, outcome, next)
+			}
+		}
+	}
+	return nil
+}
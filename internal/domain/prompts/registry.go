@@ -0,0 +1,174 @@
+package prompts
+
+import (
+	"embed"
+	"fmt"
+	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/interfaces"
+	"io/fs"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// Templates are the prompt templates shipped with the binary, one directory per
+// template holding one file per version, e.g. summarize/v2.tmpl. A published
+// version is never changed nor removed.
+//
+//go:embed templates
+var Templates embed.FS
+
+// Supported is, by template, the first version asking for the answer its
+// response schema describes. The older ones stay loaded, for the retries of
+// the prompts rendered with them, but may not be pinned: the response parser
+// rejects every answer they ask for.
+var Supported = map[string]int{
+	"language":        2,
+	"location":        2,
+	"overall":         2,
+	"sentences":       3,
+	"summarize":       2,
+	"worth-checking":  3,
+	"worth-summarize": 3,
+}
+
+const extension = ".tmpl"
+
+// FS is the directory to load the templates from, so they can be changed
+// without a new build, or the shipped templates when dir is empty.
+func FS(dir string) (fs.FS, error) {
+	if dir != "" {
+		return os.DirFS(dir), nil
+	}
+	return fs.Sub(Templates, "templates")
+}
+
+type version struct {
+	number   int
+	template *template.Template
+}
+
+// registry holds every version of every prompt template and renders the active
+// one, which is the latest version unless another one is pinned.
+type registry struct {
+	templates map[string]map[string]version
+	active    map[string]string
+}
+
+func (r registry) Render(name, version string, data any) (string, string, error) {
+	if version == "" {
+		active, ok := r.active[name]
+		if !ok {
+			return "", "", fmt.Errorf("prompt template '%s' not found", name)
+		}
+		version = active
+	}
+
+	v, ok := r.templates[name][version]
+	if !ok {
+		return "", "", fmt.Errorf("version '%s' of prompt template '%s' not found", version, name)
+	}
+
+	var b strings.Builder
+	err := v.template.Execute(&b, data)
+	if err != nil {
+		return "", "", err
+	}
+	return b.String(), version, nil
+}
+
+func parseVersion(file string) (int, error) {
+	v := strings.TrimSuffix(file, extension)
+	if !strings.HasPrefix(v, "v") {
+		return 0, fmt.Errorf("prompt template version '%s' must look like v1", file)
+	}
+	return strconv.Atoi(strings.TrimPrefix(v, "v"))
+}
+
+func load(fsys fs.FS, name string, funcs template.FuncMap) (map[string]version, error) {
+	files, err := fs.ReadDir(fsys, name)
+	if err != nil {
+		return nil, err
+	}
+
+	versions := map[string]version{}
+	for _, file := range files {
+		if file.IsDir() || path.Ext(file.Name()) != extension {
+			continue
+		}
+
+		number, err := parseVersion(file.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		src, err := fs.ReadFile(fsys, path.Join(name, file.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		id := strings.TrimSuffix(file.Name(), extension)
+		t, err := template.New(name + "/" + id).
+			Option("missingkey=error").
+			Funcs(funcs).
+			Parse(strings.TrimSuffix(string(src), "\n"))
+		if err != nil {
+			return nil, err
+		}
+		versions[id] = version{number: number, template: t}
+	}
+	return versions, nil
+}
+
+// NewRegistry loads every template found in fsys, which may call funcs. pinned
+// tells which version of a template to use, the latest one being used when it
+// returns "". A version older than the supported one of its template may not
+// be pinned.
+func NewRegistry(fsys fs.FS, pinned func(name string) string, supported map[string]int, funcs template.FuncMap) (interfaces.PromptRegistry, error) {
+	dirs, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	r := &registry{
+		templates: map[string]map[string]version{},
+		active:    map[string]string{},
+	}
+	for _, dir := range dirs {
+		if !dir.IsDir() {
+			continue
+		}
+		name := dir.Name()
+
+		versions, err := load(fsys, name, funcs)
+		if err != nil {
+			return nil, err
+		}
+		if len(versions) == 0 {
+			continue
+		}
+		r.templates[name] = versions
+
+		if pin := pinned(name); pin != "" {
+			v, ok := versions[pin]
+			if !ok {
+				return nil, fmt.Errorf("pinned version '%s' of prompt template '%s' not found", pin, name)
+			}
+			if v.number < supported[name] {
+				return nil, fmt.Errorf("pinned version '%s' of prompt template '%s' is no longer supported, pin v%d or later", pin, name, supported[name])
+			}
+			r.active[name] = pin
+			continue
+		}
+
+		latest := -1
+		for id, v := range versions {
+			if v.number > latest {
+				latest = v.number
+				r.active[name] = id
+			}
+		}
+	}
+	return r, nil
+}
@@ -0,0 +1,148 @@
+package prompts
+
+import (
+	"io/fs"
+	"path"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"text/template"
+)
+
+var testTemplates = fstest.MapFS{
+	"summarize/v1.tmpl":  {Data: []byte("v1 {{.Content}}\n")},
+	"summarize/v2.tmpl":  {Data: []byte("v2 {{.Content}}\n")},
+	"summarize/v10.tmpl": {Data: []byte("v10 {{.Content}}\n")},
+	"overall/v1.tmpl":    {Data: []byte("overall {{upper .Content}}\n")},
+	"overall/notes.md":   {Data: []byte("ignored")},
+}
+
+var testSupported = map[string]int{"summarize": 2}
+
+var testFuncs = template.FuncMap{"upper": strings.ToUpper}
+
+func pins(versions map[string]string) func(string) string {
+	return func(name string) string {
+		return versions[name]
+	}
+}
+
+func TestNewRegistry(t *testing.T) {
+	tests := []struct {
+		name    string
+		fsys    fs.FS
+		pinned  map[string]string
+		wantErr string
+	}{
+		{"latest versions", testTemplates, nil, ""},
+		{"pinned version", testTemplates, map[string]string{"summarize": "v2"}, ""},
+		{"unknown pin", testTemplates, map[string]string{"summarize": "v3"}, "pinned version 'v3' of prompt template 'summarize' not found"},
+		{"unsupported pin", testTemplates, map[string]string{"summarize": "v1"}, "pinned version 'v1' of prompt template 'summarize' is no longer supported, pin v2 or later"},
+		{"bad version name", fstest.MapFS{"summarize/latest.tmpl": {Data: []byte("x")}}, nil, "prompt template version 'latest.tmpl' must look like v1"},
+		{"bad template", fstest.MapFS{"summarize/v1.tmpl": {Data: []byte("{{.Content")}}, nil, "template: summarize/v1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewRegistry(tt.fsys, pins(tt.pinned), testSupported, testFuncs)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("NewRegistry() error = %v, want none", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("NewRegistry() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRender(t *testing.T) {
+	data := map[string]string{"Content": "page"}
+
+	tests := []struct {
+		name        string
+		pinned      map[string]string
+		template    string
+		version     string
+		want        string
+		wantVersion string
+		wantErr     string
+	}{
+		{"latest by number", nil, "summarize", "", "v10 page", "v10", ""},
+		{"pinned", map[string]string{"summarize": "v2"}, "summarize", "", "v2 page", "v2", ""},
+		{"given unsupported version", nil, "summarize", "v1", "v1 page", "v1", ""},
+		{"given version over the pin", map[string]string{"summarize": "v2"}, "summarize", "v1", "v1 page", "v1", ""},
+		{"funcs", nil, "overall", "", "overall PAGE", "v1", ""},
+		{"unknown template", nil, "sentences", "", "", "", "prompt template 'sentences' not found"},
+		{"unknown version", nil, "summarize", "v3", "", "", "version 'v3' of prompt template 'summarize' not found"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			registry, err := NewRegistry(testTemplates, pins(tt.pinned), testSupported, testFuncs)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, version, err := registry.Render(tt.template, tt.version, data)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Errorf("Render() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want || version != tt.wantVersion {
+				t.Errorf("Render() = %q, %q, want %q, %q", got, version, tt.want, tt.wantVersion)
+			}
+		})
+	}
+}
+
+// TestShippedTemplates checks every shipped version parses and that, but for
+// the correction, the supported ones ask for the answer described by a
+// response schema.
+func TestShippedTemplates(t *testing.T) {
+	fsys, err := FS("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || strings.HasPrefix(name, "correction/") {
+			return err
+		}
+
+		dir, file := path.Split(name)
+		number, err := parseVersion(file)
+		if err != nil {
+			return err
+		}
+		if number < Supported[strings.TrimSuffix(dir, "/")] {
+			return nil
+		}
+
+		src, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return err
+		}
+		if !strings.Contains(string(src), "{{schema ") {
+			t.Errorf("%s does not ask for the response schema", name)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	funcs := template.FuncMap{
+		"schema":       func(string) string { return "" },
+		"fence":        func(string) string { return "" },
+		"languageName": func(string) string { return "" },
+	}
+	if _, err = NewRegistry(fsys, pins(nil), Supported, funcs); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,102 @@
+package schemas
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/interfaces"
+	"io/fs"
+	"path"
+	"strings"
+)
+
+// Definitions are the schemas of the answer of every action, named after it,
+// e.g. summarize.json.
+//
+//go:embed definitions
+var Definitions embed.FS
+
+type responseParser struct {
+	schemas map[string]*Schema
+	sources map[string]string
+}
+
+func (p responseParser) Schema(action string) (string, error) {
+	source, ok := p.sources[action]
+	if !ok {
+		return "", fmt.Errorf("response schema of action '%s' not found", action)
+	}
+	return source, nil
+}
+
+// unwrap removes the markdown code fence models often put around JSON.
+func unwrap(response string) string {
+	response = strings.TrimSpace(response)
+	if !strings.HasPrefix(response, "```") {
+		return response
+	}
+
+	response = strings.TrimPrefix(response, "```")
+	response = strings.TrimPrefix(response, "json")
+	response = strings.TrimSuffix(response, "```")
+	return strings.TrimSpace(response)
+}
+
+func (p responseParser) Parse(action, response string, v any) []string {
+	schema, ok := p.schemas[action]
+	if !ok {
+		return []string{fmt.Sprintf("response schema of action '%s' not found", action)}
+	}
+
+	raw := []byte(unwrap(response))
+
+	var document any
+	err := json.Unmarshal(raw, &document)
+	if err != nil {
+		return []string{fmt.Sprintf("response is not valid JSON: %s", err.Error())}
+	}
+
+	messages := schema.Validate("$", document)
+	if len(messages) > 0 {
+		return messages
+	}
+
+	err = json.Unmarshal(raw, v)
+	if err != nil {
+		return []string{fmt.Sprintf("response does not match the expected answer: %s", err.Error())}
+	}
+	return nil
+}
+
+func NewResponseParser(fsys fs.FS) (interfaces.ResponseParser, error) {
+	files, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	p := &responseParser{
+		schemas: map[string]*Schema{},
+		sources: map[string]string{},
+	}
+	for _, file := range files {
+		if file.IsDir() || path.Ext(file.Name()) != ".json" {
+			continue
+		}
+
+		b, err := fs.ReadFile(fsys, file.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		var schema Schema
+		err = json.Unmarshal(b, &schema)
+		if err != nil {
+			return nil, fmt.Errorf("response schema %s: %w", file.Name(), err)
+		}
+
+		action := strings.TrimSuffix(file.Name(), ".json")
+		p.schemas[action] = &schema
+		p.sources[action] = strings.TrimSpace(string(b))
+	}
+	return p, nil
+}
@@ -0,0 +1,77 @@
+package schemas
+
+import (
+	"io/fs"
+	"strings"
+	"testing"
+)
+
+func TestUnwrap(t *testing.T) {
+	tests := []struct {
+		name     string
+		response string
+		want     string
+	}{
+		{"bare", `{"summary": "s"}`, `{"summary": "s"}`},
+		{"surrounding spaces", "\n  {\"summary\": \"s\"}  \n", `{"summary": "s"}`},
+		{"fence", "```\n{\"summary\": \"s\"}\n```", `{"summary": "s"}`},
+		{"json fence", "```json\n{\"summary\": \"s\"}\n```", `{"summary": "s"}`},
+		{"fenced with spaces", "  ```json\n{\"summary\": \"s\"}\n```\n", `{"summary": "s"}`},
+		{"unterminated fence", "```json\n{\"summary\": \"s\"}", `{"summary": "s"}`},
+		{"backticks inside", "{\"summary\": \"```\"}", "{\"summary\": \"```\"}"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := unwrap(tt.response)
+			if got != tt.want {
+				t.Errorf("unwrap(%q) = %q, want %q", tt.response, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	fsys, err := fs.Sub(Definitions, "definitions")
+	if err != nil {
+		t.Fatal(err)
+	}
+	parser, err := NewResponseParser(fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		action   string
+		response string
+		want     string
+		problem  string
+	}{
+		{"valid", "summarize", `{"summary": "solar panels"}`, "solar panels", ""},
+		{"fenced", "summarize", "```json\n{\"summary\": \"solar panels\"}\n```", "solar panels", ""},
+		{"plain text", "summarize", "solar panels", "", "response is not valid JSON"},
+		{"against the schema", "summarize", `{"summary": ""}`, "", "$.summary: must have at least 1 characters"},
+		{"unknown action", "unknown", `{"summary": "s"}`, "", "response schema of action 'unknown' not found"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var answer struct {
+				Summary string `json:"summary"`
+			}
+			problems := parser.Parse(tt.action, tt.response, &answer)
+
+			if tt.problem == "" {
+				if len(problems) > 0 {
+					t.Fatalf("Parse(%q) = %q, want no problem", tt.response, problems)
+				}
+				if answer.Summary != tt.want {
+					t.Errorf("Parse(%q) decoded %q, want %q", tt.response, answer.Summary, tt.want)
+				}
+				return
+			}
+			if len(problems) != 1 || !strings.HasPrefix(problems[0], tt.problem) {
+				t.Errorf("Parse(%q) = %q, want %q", tt.response, problems, tt.problem)
+			}
+		})
+	}
+}
@@ -0,0 +1,127 @@
+package schemas
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// Schema is the subset of JSON schema used to describe the LLM answers.
+type Schema struct {
+	Type                 string             `json:"type,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *bool              `json:"additionalProperties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Enum                 []any              `json:"enum,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty"`
+	MaxItems             *int               `json:"maxItems,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+}
+
+func typeOf(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		if v == math.Trunc(v) {
+			return "integer"
+		}
+		return "number"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+func (s *Schema) matchesType(v any) bool {
+	t := typeOf(v)
+	return s.Type == "" || s.Type == t || (s.Type == "number" && t == "integer")
+}
+
+func (s *Schema) allows(v any) bool {
+	switch v.(type) {
+	case []any, map[string]any:
+		return false
+	}
+	return slices.Contains(s.Enum, v)
+}
+
+// Validate tells every way v, decoded from JSON, does not match the schema.
+// Messages are prefixed with the path of the offending value, e.g. $.items[2].
+func (s *Schema) Validate(path string, v any) []string {
+	if !s.matchesType(v) {
+		return []string{fmt.Sprintf("%s: expected %s, got %s", path, s.Type, typeOf(v))}
+	}
+
+	var messages []string
+	if len(s.Enum) > 0 && !s.allows(v) {
+		messages = append(messages, fmt.Sprintf("%s: %v is not one of %v", path, v, s.Enum))
+	}
+
+	switch v := v.(type) {
+	case string:
+		length := utf8.RuneCountInString(v)
+		if s.MinLength != nil && length < *s.MinLength {
+			messages = append(messages, fmt.Sprintf("%s: must have at least %d characters", path, *s.MinLength))
+		}
+		if s.MaxLength != nil && length > *s.MaxLength {
+			messages = append(messages, fmt.Sprintf("%s: must have at most %d characters", path, *s.MaxLength))
+		}
+	case float64:
+		if s.Minimum != nil && v < *s.Minimum {
+			messages = append(messages, fmt.Sprintf("%s: must be at least %v", path, *s.Minimum))
+		}
+		if s.Maximum != nil && v > *s.Maximum {
+			messages = append(messages, fmt.Sprintf("%s: must be at most %v", path, *s.Maximum))
+		}
+	case []any:
+		if s.MinItems != nil && len(v) < *s.MinItems {
+			messages = append(messages, fmt.Sprintf("%s: must have at least %d items", path, *s.MinItems))
+		}
+		if s.MaxItems != nil && len(v) > *s.MaxItems {
+			messages = append(messages, fmt.Sprintf("%s: must have at most %d items", path, *s.MaxItems))
+		}
+		if s.Items != nil {
+			for i, item := range v {
+				messages = append(messages, s.Items.Validate(fmt.Sprintf("%s[%d]", path, i), item)...)
+			}
+		}
+	case map[string]any:
+		for _, name := range s.Required {
+			if _, ok := v[name]; !ok {
+				messages = append(messages, fmt.Sprintf("%s: %q is required", path, name))
+			}
+		}
+
+		names := make([]string, 0, len(v))
+		for name := range v {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			property, ok := s.Properties[name]
+			if !ok {
+				if s.AdditionalProperties != nil && !*s.AdditionalProperties {
+					messages = append(messages, fmt.Sprintf("%s: %q is not allowed", path, name))
+				}
+				continue
+			}
+			messages = append(messages, property.Validate(strings.Join([]string{path, name}, "."), v[name])...)
+		}
+	}
+	return messages
+}
@@ -0,0 +1,62 @@
+package schemas
+
+import (
+	"encoding/json"
+	"slices"
+	"testing"
+)
+
+const testSchema = `{
+  "type": "object",
+  "properties": {
+    "score": {"type": "integer", "minimum": 0, "maximum": 100},
+    "ratio": {"type": "number"},
+    "reason": {"type": "string", "minLength": 2, "maxLength": 5},
+    "tags": {"type": "array", "minItems": 1, "maxItems": 2, "items": {"type": "string", "enum": ["a", "b"]}}
+  },
+  "required": ["score"],
+  "additionalProperties": false
+}`
+
+func TestValidate(t *testing.T) {
+	var schema Schema
+	if err := json.Unmarshal([]byte(testSchema), &schema); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		document string
+		want     []string
+	}{
+		{"valid", `{"score": 10, "ratio": 0.5, "reason": "ok", "tags": ["a", "b"]}`, nil},
+		{"integer accepted as number", `{"score": 10, "ratio": 1}`, nil},
+		{"wrong root type", `["score"]`, []string{"$: expected object, got array"}},
+		{"missing required", `{}`, []string{`$: "score" is required`}},
+		{"additional property", `{"score": 1, "extra": true}`, []string{`$: "extra" is not allowed`}},
+		{"not an integer", `{"score": 1.5}`, []string{"$.score: expected integer, got number"}},
+		{"below minimum", `{"score": -1}`, []string{"$.score: must be at least 0"}},
+		{"above maximum", `{"score": 101}`, []string{"$.score: must be at most 100"}},
+		{"too short", `{"score": 1, "reason": "a"}`, []string{"$.reason: must have at least 2 characters"}},
+		{"too long", `{"score": 1, "reason": "abcdef"}`, []string{"$.reason: must have at most 5 characters"}},
+		{"length counts characters", `{"score": 1, "reason": "ãéíõú"}`, nil},
+		{"too few items", `{"score": 1, "tags": []}`, []string{"$.tags: must have at least 1 items"}},
+		{"too many items", `{"score": 1, "tags": ["a", "a", "b"]}`, []string{"$.tags: must have at most 2 items"}},
+		{"item not in enum", `{"score": 1, "tags": ["c"]}`, []string{"$.tags[0]: c is not one of [a b]"}},
+		{"wrong item type", `{"score": 1, "tags": [1]}`, []string{"$.tags[0]: expected string, got integer"}},
+		{"every problem", `{"score": "1", "extra": 1}`, []string{`$: "extra" is not allowed`, "$.score: expected integer, got string"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var document any
+			if err := json.Unmarshal([]byte(tt.document), &document); err != nil {
+				t.Fatal(err)
+			}
+
+			got := schema.Validate("$", document)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Validate(%s) = %q, want %q", tt.document, got, tt.want)
+			}
+		})
+	}
+}
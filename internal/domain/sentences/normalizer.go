@@ -0,0 +1,71 @@
+package sentences
+
+import (
+	"fmt"
+	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/models"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// enumeration matches the numbering or bullet the LLM puts before a sentence
+// despite being asked not to, e.g. "1.", "2)", "3 -" or "*".
+var enumeration = regexp.MustCompile(`^(\d+\s*[.):\-]|[-*•])(\s+|$)`)
+
+const quotes = `"'“”‘’«»` + "`"
+
+// Clean strips the enumeration, the quotes and the extra spaces of a sentence
+// and lowercases it. A sentence made only of those is cleaned to "".
+func Clean(sentence string) string {
+	sentence = strings.Trim(sentence, quotes+" \t\r\n")
+	sentence = enumeration.ReplaceAllString(sentence, "")
+	sentence = strings.Trim(sentence, quotes+" \t\r\n")
+	return strings.ToLower(strings.Join(strings.Fields(sentence), " "))
+}
+
+// code lowercases a language or country code, keeping only the language of
+// regional codes such as "pt-BR".
+func code(value string) string {
+	value = strings.ToLower(strings.TrimSpace(value))
+	language, _, _ := strings.Cut(value, "-")
+	return language
+}
+
+// Normalize cleans the sentences, drops the empty and repeated ones and keeps
+// the first count of them. It tells what is wrong with the answer when a tag
+// is not one of the languages or locations of the request, or when fewer than
+// count sentences are left. Empty languages or locations accept any tag.
+func Normalize(entries []models.Sentence, count int, languages, locations []string) ([]models.Sentence, []string) {
+	var (
+		res      []models.Sentence
+		problems []string
+		seen     = map[string]bool{}
+	)
+	for _, entry := range entries {
+		sentence := Clean(entry.Sentence)
+		if sentence == "" || seen[sentence] {
+			continue
+		}
+		seen[sentence] = true
+
+		language, country := code(entry.Language), code(entry.Country)
+		if len(languages) > 0 && !slices.Contains(languages, language) {
+			problems = append(problems, fmt.Sprintf(`language "%s" of sentence "%s" is not one of %s`, entry.Language, sentence, strings.Join(languages, ",")))
+			continue
+		}
+		if len(locations) > 0 && !slices.Contains(locations, country) {
+			problems = append(problems, fmt.Sprintf(`country "%s" of sentence "%s" is not one of %s`, entry.Country, sentence, strings.Join(locations, ",")))
+			continue
+		}
+
+		res = append(res, models.Sentence{Sentence: sentence, Language: language, Country: country})
+	}
+
+	if len(problems) > 0 {
+		return nil, problems
+	}
+	if len(res) < count {
+		return nil, []string{fmt.Sprintf("%d distinct sentences are required, %d were given", count, len(res))}
+	}
+	return res[:count], nil
+}
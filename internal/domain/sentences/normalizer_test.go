@@ -0,0 +1,104 @@
+package sentences
+
+import (
+	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/models"
+	"slices"
+	"testing"
+)
+
+func TestClean(t *testing.T) {
+	tests := []struct {
+		sentence string
+		want     string
+	}{
+		{"Solar panels in Brazil", "solar panels in brazil"},
+		{"1. solar panels", "solar panels"},
+		{"2) solar panels", "solar panels"},
+		{"3 - solar panels", "solar panels"},
+		{"* solar panels", "solar panels"},
+		{"• solar panels", "solar panels"},
+		{`"solar panels"`, "solar panels"},
+		{`1. “solar   panels”`, "solar panels"},
+		{"  solar\tpanels \n", "solar panels"},
+		{"2024 solar panels", "2024 solar panels"},
+		{`"1."`, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.sentence, func(t *testing.T) {
+			got := Clean(tt.sentence)
+			if got != tt.want {
+				t.Errorf("Clean(%q) = %q, want %q", tt.sentence, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	s := func(sentence, language, country string) models.Sentence {
+		return models.Sentence{Sentence: sentence, Language: language, Country: country}
+	}
+
+	tests := []struct {
+		name         string
+		entries      []models.Sentence
+		count        int
+		languages    []string
+		locations    []string
+		want         []models.Sentence
+		wantProblems []string
+	}{
+		{
+			name:      "cleans the sentences and the tags",
+			entries:   []models.Sentence{s("1. Solar Panels", "PT-br", " BR "), s(`"energia solar"`, "pt", "br")},
+			count:     2,
+			languages: []string{"pt"},
+			locations: []string{"br"},
+			want:      []models.Sentence{s("solar panels", "pt", "br"), s("energia solar", "pt", "br")},
+		},
+		{
+			name:    "drops empty and repeated sentences",
+			entries: []models.Sentence{s("solar", "en", "us"), s("1.", "en", "us"), s("Solar", "en", "us"), s("wind", "en", "us")},
+			count:   2,
+			want:    []models.Sentence{s("solar", "en", "us"), s("wind", "en", "us")},
+		},
+		{
+			name:    "keeps the first count",
+			entries: []models.Sentence{s("a", "en", "us"), s("b", "en", "us"), s("c", "en", "us")},
+			count:   2,
+			want:    []models.Sentence{s("a", "en", "us"), s("b", "en", "us")},
+		},
+		{
+			name:         "too few sentences",
+			entries:      []models.Sentence{s("a", "en", "us"), s("A", "en", "us")},
+			count:        2,
+			wantProblems: []string{"2 distinct sentences are required, 1 were given"},
+		},
+		{
+			name:         "unknown language",
+			entries:      []models.Sentence{s("a", "fr", "br"), s("b", "pt", "br")},
+			count:        1,
+			languages:    []string{"pt", "en"},
+			locations:    []string{"br"},
+			wantProblems: []string{`language "fr" of sentence "a" is not one of pt,en`},
+		},
+		{
+			name:         "unknown country",
+			entries:      []models.Sentence{s("a", "pt", "pt"), s("b", "pt", "br")},
+			count:        1,
+			languages:    []string{"pt"},
+			locations:    []string{"br"},
+			wantProblems: []string{`country "pt" of sentence "a" is not one of br`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, problems := Normalize(tt.entries, tt.count, tt.languages, tt.locations)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Normalize() = %v, want %v", got, tt.want)
+			}
+			if !slices.Equal(problems, tt.wantProblems) {
+				t.Errorf("Normalize() problems = %q, want %q", problems, tt.wantProblems)
+			}
+		})
+	}
+}
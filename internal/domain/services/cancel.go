@@ -0,0 +1,88 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/config/errortypes"
+	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/builder"
+	enumstatuses "github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/enums/statuses"
+	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/interfaces"
+	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"log/slog"
+	"time"
+)
+
+type cancelService struct {
+	queueStatusManager     interfaces.Queue
+	orchestratorRepository interfaces.OrchestratorRepository
+}
+
+func (l cancelService) Execute(ctx context.Context, orchestratorRequest models.AiOrchestratorRequest) error {
+	slog.InfoContext(ctx, "cancelService.Execute",
+		slog.String("details", "process started"))
+
+	var request models.OrchestratorRequest
+	err := l.orchestratorRepository.GetById(ctx, *orchestratorRequest.RequestId, &request)
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		slog.ErrorContext(ctx, "cancelService.Execute",
+			slog.String("details", "process error"),
+			slog.String("error", err.Error()))
+		return err
+	}
+
+	if request.CancelledAt != nil {
+		slog.WarnContext(ctx, "cancelService.Execute",
+			slog.String("details", "request already cancelled"))
+		return nil
+	}
+
+	// Upserted, so a cancel that arrives before location created the request
+	// is kept and the request is dropped when location runs.
+	now := time.Now().UTC()
+	err = l.orchestratorRepository.Upsert(ctx, *orchestratorRequest.RequestId,
+		bson.M{"cancelledAt": now, "updatedAt": now},
+	)
+	if err != nil {
+		slog.ErrorContext(ctx, "cancelService.Execute",
+			slog.String("details", "process error"),
+			slog.String("error", err.Error()))
+		return err
+	}
+
+	b, err := builder.BuildQueueStatusManagerMessage(orchestratorRequest.RequestId, nil, enumstatuses.Cancelled)
+	if err != nil {
+		slog.ErrorContext(ctx, "cancelService.Execute",
+			slog.String("details", "process error"),
+			slog.String("error", err.Error()))
+		return err
+	}
+
+	err = l.queueStatusManager.Publish(ctx, b)
+	if err != nil {
+		slog.ErrorContext(ctx, "cancelService.Execute",
+			slog.String("details", "process error"),
+			slog.String("error", err.Error()))
+		return err
+	}
+
+	slog.InfoContext(ctx, "cancelService.Execute",
+		slog.String("details", "process finished"))
+	return nil
+}
+
+func (l cancelService) Callback(ctx context.Context, _ models.AiOrchestratorCallbackRequest) (string, error) {
+	err := errortypes.NewValidationException(`"cancel" does not expect an AI callback`)
+	slog.ErrorContext(ctx, "cancelService.Callback",
+		slog.String("details", "process error"),
+		slog.String("error", err.Error()))
+	return "", err
+}
+
+func NewCancelService(queueStatusManager interfaces.Queue, orchestratorRepository interfaces.OrchestratorRepository) interfaces.Service {
+	return &cancelService{
+		queueStatusManager:     queueStatusManager,
+		orchestratorRepository: orchestratorRepository,
+	}
+}
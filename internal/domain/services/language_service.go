@@ -4,10 +4,9 @@ import (
 	"context"
 	"fmt"
 	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/config/errortypes"
-	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/config/properties"
-	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/delivery/dtos"
-	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/builder"
+	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/acceptance"
 	enumactions "github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/enums/actions"
+	enumoutcomes "github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/enums/outcomes"
 	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/interfaces"
 	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/models"
 	nosqlmodels "github.com/PesquisAi/pesquisai-database-lib/nosql/models"
@@ -19,28 +18,38 @@ import (
 	"strings"
 )
 
-const (
-	questionTemplate = `You are a part of a major project. In this project I will perform a google search, and your only` +
-		` responsibility is to answer me, given the context of the pearson/company that are asking, the desired research` +
-		` and the countries that will be used filter the results, what are the best languages that I should use to filter the Google search results. You should answer with a list of 2 digit ` +
-		`language codes. Respond only with a comma separated list of language codes, nothing else. Consider that if the research will be filtered by the countries below, makes sense to match the country languages.` +
-		` But any language that makes sense in the research context must be use.` +
-		`Here I have a list of the codes you can use: %s. person/company context:"%s". research:"%s". countries:"%s".`
-)
+type languageQuestion struct {
+	Languages string
+	Context   string
+	Research  string
+	Locations string
+}
+
+type languageAnswer struct {
+	Languages []string `json:"languages"`
+}
 
 type languageService struct {
-	queueGemini            interfaces.Queue
-	queueOrchestrator      interfaces.Queue
+	promptDispatcher       interfaces.PromptDispatcher
+	promptRegistry         interfaces.PromptRegistry
+	responseParser         interfaces.ResponseParser
+	acceptancePolicy       acceptance.Policy
 	requestRepository      interfaces.RequestRepository
 	orchestratorRepository interfaces.OrchestratorRepository
 }
 
-func (l languageService) validateGeminiResponse(response []string) ([]string, []string) {
-	var errorMessages, res []string
-	for i, split := range response {
+func (l languageService) validateGeminiResponse(response string) ([]string, []string) {
+	var answer languageAnswer
+	errorMessages := l.responseParser.Parse(enumactions.Language, response, &answer)
+	if errorMessages != nil {
+		return nil, errorMessages
+	}
+
+	var res []string
+	for _, split := range answer.Languages {
+		split = strings.ToLower(split)
 		if strings.Contains(split, "-") {
 			split = strings.Split(split, "-")[0]
-			response[i] = split
 		}
 
 		if !slices.Contains(enumlanguages.Languages, split) {
@@ -50,8 +59,15 @@ func (l languageService) validateGeminiResponse(response []string) ([]string, []
 		res = append(res, split)
 	}
 
+	rejection := l.acceptancePolicy.Reject(len(res), errorMessages)
+	if rejection != nil {
+		return nil, rejection
+	}
+
 	if len(errorMessages) > 0 {
-		return nil, errorMessages
+		slog.Warn("languageService.validateGeminiResponse",
+			slog.String("details", "invalid languages dropped by the acceptance policy"),
+			slog.Any("dropped", errorMessages))
 	}
 
 	return res, nil
@@ -94,15 +110,7 @@ func (l languageService) Execute(ctx context.Context, orchestratorRequest models
 		return err
 	}
 
-	question := l.buildQuestion(request)
-
-	b, err := builder.BuildQueueGeminiMessage(
-		*orchestratorRequest.RequestId,
-		question,
-		properties.QueueNameAiOrchestratorCallback,
-		enumactions.Language,
-		0,
-	)
+	question, version, err := l.buildQuestion(request, "")
 	if err != nil {
 		slog.ErrorContext(ctx, "languageService.Execute",
 			slog.String("details", "process error"),
@@ -110,7 +118,14 @@ func (l languageService) Execute(ctx context.Context, orchestratorRequest models
 		return err
 	}
 
-	err = l.queueGemini.Publish(ctx, b)
+	action := enumactions.Language
+	err = l.promptDispatcher.Dispatch(ctx, models.Prompt{
+		RequestId:       orchestratorRequest.RequestId,
+		ResearchId:      orchestratorRequest.ResearchId,
+		Action:          &action,
+		Question:        &question,
+		TemplateVersion: &version,
+	})
 	if err != nil {
 		slog.ErrorContext(ctx, "languageService.Execute",
 			slog.String("details", "process error"),
@@ -121,22 +136,20 @@ func (l languageService) Execute(ctx context.Context, orchestratorRequest models
 	return nil
 }
 
-func (l languageService) buildQuestion(request nosqlmodels.Request) string {
-	return fmt.Sprintf(
-		questionTemplate,
-		strings.Join(enumlanguages.Languages, ","),
-		*request.Context,
-		*request.Research,
-		strings.Join(*request.Locations, ","),
-	)
+func (l languageService) buildQuestion(request nosqlmodels.Request, templateVersion string) (question, version string, err error) {
+	return l.promptRegistry.Render(enumactions.Language, templateVersion, languageQuestion{
+		Languages: strings.Join(enumlanguages.Languages, ","),
+		Context:   *request.Context,
+		Research:  *request.Research,
+		Locations: strings.Join(*request.Locations, ","),
+	})
 }
 
-func (l languageService) Callback(ctx context.Context, callback models.AiOrchestratorCallbackRequest) error {
+func (l languageService) Callback(ctx context.Context, callback models.AiOrchestratorCallbackRequest) (string, error) {
 	slog.InfoContext(ctx, "languageService.Callback",
 		slog.String("details", "process started"))
 
-	languages := strings.Split(strings.ToLower(*callback.Response), ",")
-	languages, errMessages := l.validateGeminiResponse(languages)
+	languages, errMessages := l.validateGeminiResponse(*callback.Response)
 	if errMessages != nil {
 		var request nosqlmodels.Request
 		err := l.orchestratorRepository.GetById(ctx, *callback.RequestId, &request)
@@ -144,14 +157,20 @@ func (l languageService) Callback(ctx context.Context, callback models.AiOrchest
 			slog.ErrorContext(ctx, "languageService.Execute",
 				slog.String("details", "process error"),
 				slog.String("error", err.Error()))
-			return err
+			return "", err
 		}
-		question := l.buildQuestion(request)
-		err = errortypes.NewInvalidAIResponseException(*callback.RequestId, question, enumactions.Language, callback.ReceiveCount+1, errMessages...)
+		question, version, err := l.buildQuestion(request, attemptVersion(callback))
+		if err != nil {
+			slog.ErrorContext(ctx, "languageService.Callback",
+				slog.String("details", "process error"),
+				slog.String("error", err.Error()))
+			return "", err
+		}
+		err = errortypes.NewInvalidAIResponseException(retryForward(callback, question, version), errMessages...)
 		slog.ErrorContext(ctx, "languageService.Callback",
 			slog.String("details", "process error"),
 			slog.String("error", err.Error()))
-		return err
+		return "", err
 	}
 
 	g, groupCtx := errgroup.WithContext(ctx)
@@ -170,7 +189,7 @@ func (l languageService) Callback(ctx context.Context, callback models.AiOrchest
 		slog.ErrorContext(ctx, "languageService.Callback",
 			slog.String("details", "process error"),
 			slog.String("error", err.Error()))
-		return err
+		return "", err
 	}
 
 	err = l.orchestratorRepository.Update(ctx, *callback.RequestId,
@@ -180,41 +199,20 @@ func (l languageService) Callback(ctx context.Context, callback models.AiOrchest
 		slog.ErrorContext(ctx, "languageService.Callback",
 			slog.String("details", "process error"),
 			slog.String("error", err.Error()))
-		return err
-	}
-
-	var (
-		b      []byte
-		action = enumactions.Sentences
-	)
-	b, err = builder.BuildQueueOrchestratorMessage(dtos.AiOrchestratorRequest{
-		RequestId: callback.RequestId,
-		Action:    &action,
-	})
-	if err != nil {
-		slog.ErrorContext(ctx, "languageService.Callback",
-			slog.String("details", "process error"),
-			slog.String("error", err.Error()))
-		return err
-	}
-
-	err = l.queueOrchestrator.Publish(ctx, b)
-	if err != nil {
-		slog.ErrorContext(ctx, "languageService.Callback",
-			slog.String("details", "process error"),
-			slog.String("error", err.Error()))
-		return err
+		return "", err
 	}
 
 	slog.InfoContext(ctx, "languageService.Callback",
 		slog.String("details", "process finished"))
-	return nil
+	return enumoutcomes.Done, nil
 }
-func NewLanguageService(queueGemini, queueOrchestrator interfaces.Queue, orchestratorRepository interfaces.OrchestratorRepository, requestRepository interfaces.RequestRepository) interfaces.Service {
+func NewLanguageService(promptDispatcher interfaces.PromptDispatcher, promptRegistry interfaces.PromptRegistry, responseParser interfaces.ResponseParser, acceptancePolicy acceptance.Policy, orchestratorRepository interfaces.OrchestratorRepository, requestRepository interfaces.RequestRepository) interfaces.Service {
 	return &languageService{
-		queueGemini:            queueGemini,
+		promptDispatcher:       promptDispatcher,
+		promptRegistry:         promptRegistry,
+		responseParser:         responseParser,
+		acceptancePolicy:       acceptancePolicy,
 		requestRepository:      requestRepository,
 		orchestratorRepository: orchestratorRepository,
-		queueOrchestrator:      queueOrchestrator,
 	}
 }
@@ -2,17 +2,20 @@ package services
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/config/errortypes"
-	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/config/properties"
-	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/delivery/dtos"
-	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/builder"
+	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/acceptance"
 	enumactions "github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/enums/actions"
+	enumoutcomes "github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/enums/outcomes"
 	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/interfaces"
+	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/language"
 	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/models"
 	nosqlmodels "github.com/PesquisAi/pesquisai-database-lib/nosql/models"
+	enumlanguages "github.com/PesquisAi/pesquisai-database-lib/sql/enums/languages"
 	enumlocations "github.com/PesquisAi/pesquisai-database-lib/sql/enums/locations"
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
 	"golang.org/x/sync/errgroup"
 	"log/slog"
 	"slices"
@@ -20,29 +23,53 @@ import (
 	"time"
 )
 
-const (
-	locationQuestionTemplate = `You are a part of a major project. In this project I will perform a google search, and your only` +
-		` responsibility is to answer me, given the context of the pearson/company that are asking and the desired research,` +
-		` what are the best countries that I should filter the Google search results. You should answer with a list of 2 digit ` +
-		`country codes. Respond only with a comma separated list of country codes, nothing else. ` +
-		`Here I have a list of the codes you can use: %s. person/company context:"%s". research:"%s".`
-)
+type locationQuestion struct {
+	Locations string
+	Context   string
+	Research  string
+}
+
+type locationAnswer struct {
+	Locations []string `json:"locations"`
+}
 
 type locationService struct {
-	queueGemini            interfaces.Queue
-	queueOrchestrator      interfaces.Queue
+	promptDispatcher       interfaces.PromptDispatcher
+	promptRegistry         interfaces.PromptRegistry
+	responseParser         interfaces.ResponseParser
+	acceptancePolicy       acceptance.Policy
 	requestRepository      interfaces.RequestRepository
 	orchestratorRepository interfaces.OrchestratorRepository
 }
 
-func (l locationService) validateGeminiResponse(response []string) *string {
-	for _, split := range response {
-		if !slices.Contains(enumlocations.Locations, split) {
-			message := fmt.Sprintf("%s is not a valid location", split)
-			return &message
+func (l locationService) validateGeminiResponse(response string) ([]string, []string) {
+	var answer locationAnswer
+	errorMessages := l.responseParser.Parse(enumactions.Location, response, &answer)
+	if errorMessages != nil {
+		return nil, errorMessages
+	}
+
+	var res []string
+	for _, location := range answer.Locations {
+		location = strings.ToLower(location)
+		if !slices.Contains(enumlocations.Locations, location) {
+			errorMessages = append(errorMessages, fmt.Sprintf("%s is not a valid location", location))
+			continue
 		}
+		res = append(res, location)
 	}
-	return nil
+
+	rejection := l.acceptancePolicy.Reject(len(res), errorMessages)
+	if rejection != nil {
+		return nil, rejection
+	}
+
+	if len(errorMessages) > 0 {
+		slog.Warn("locationService.validateGeminiResponse",
+			slog.String("details", "invalid locations dropped by the acceptance policy"),
+			slog.Any("dropped", errorMessages))
+	}
+	return res, nil
 }
 
 func (l locationService) validateRequest(request models.AiOrchestratorRequest) error {
@@ -53,18 +80,31 @@ func (l locationService) validateRequest(request models.AiOrchestratorRequest) e
 	if request.Research == nil {
 		messages = append(messages, `"research" is required`)
 	}
+	if request.OutputLanguage != nil && !slices.Contains(enumlanguages.Languages, *request.OutputLanguage) {
+		messages = append(messages, fmt.Sprintf(`"output_language" %s is not a valid language`, *request.OutputLanguage))
+	}
 	if len(messages) > 0 {
 		return errortypes.NewValidationException(messages...)
 	}
 	return nil
 }
 
-func (l locationService) buildQuestion(context, research string) string {
-	return fmt.Sprintf(
-		locationQuestionTemplate,
-		strings.Join(enumlocations.Locations, ","),
-		context,
-		research)
+func (l locationService) buildQuestion(context, research, templateVersion string) (question, version string, err error) {
+	return l.promptRegistry.Render(enumactions.Location, templateVersion, locationQuestion{
+		Locations: strings.Join(enumlocations.Locations, ","),
+		Context:   context,
+		Research:  research,
+	})
+}
+
+// isRedelivery tells whether an already created request had its location
+// prompt dispatched or answered, in which case nothing is left to execute.
+func (l locationService) isRedelivery(ctx context.Context, request nosqlmodels.Request) (bool, error) {
+	if request.Locations != nil {
+		return true, nil
+	}
+
+	return l.promptDispatcher.Outstanding(ctx, *request.ID, nil, enumactions.Location, nil, nil)
 }
 
 func (l locationService) Execute(ctx context.Context, request models.AiOrchestratorRequest) error {
@@ -79,30 +119,61 @@ func (l locationService) Execute(ctx context.Context, request models.AiOrchestra
 		return err
 	}
 
-	createdAt := time.Now().UTC()
-	err = l.orchestratorRepository.Create(ctx, &nosqlmodels.Request{
-		ID:        request.RequestId,
-		Context:   request.Context,
-		Research:  request.Research,
-		CreatedAt: &createdAt,
-		UpdatedAt: &createdAt,
-	})
-	if err != nil {
+	outputLanguage := request.OutputLanguage
+	if outputLanguage == nil {
+		detected := language.Detect(*request.Context + "\n" + *request.Research)
+		outputLanguage = &detected
+	}
+
+	// The request is looked up before it is created: a duplicate key error
+	// aborts the transaction Execute runs in, so it cannot be recovered from.
+	// A concurrent delivery creating it first fails this one, whose retry
+	// finds the request.
+	var existing nosqlmodels.Request
+	err = l.orchestratorRepository.GetById(ctx, *request.RequestId, &existing)
+	if err == nil {
+		var redelivered bool
+		redelivered, err = l.isRedelivery(ctx, existing)
+		if err != nil {
+			slog.ErrorContext(ctx, "locationService.Execute",
+				slog.String("details", "process error"),
+				slog.String("error", err.Error()))
+			return err
+		}
+
+		if redelivered {
+			slog.InfoContext(ctx, "locationService.Execute",
+				slog.String("details", "request already created, acknowledging redelivery"))
+			return nil
+		}
+	} else if errors.Is(err, mongo.ErrNoDocuments) {
+		createdAt := time.Now().UTC()
+		err = l.orchestratorRepository.Create(ctx, &models.OrchestratorRequest{
+			Request: nosqlmodels.Request{
+				ID:        request.RequestId,
+				Context:   request.Context,
+				Research:  request.Research,
+				CreatedAt: &createdAt,
+				UpdatedAt: &createdAt,
+			},
+			OutputLanguage: outputLanguage,
+			Options:        request.Options,
+			Thresholds:     request.Thresholds,
+		})
+		if err != nil {
+			slog.ErrorContext(ctx, "locationService.Execute",
+				slog.String("details", "process error"),
+				slog.String("error", err.Error()))
+			return err
+		}
+	} else {
 		slog.ErrorContext(ctx, "locationService.Execute",
 			slog.String("details", "process error"),
 			slog.String("error", err.Error()))
 		return err
 	}
 
-	question := l.buildQuestion(*request.Context, *request.Research)
-
-	b, err := builder.BuildQueueGeminiMessage(
-		*request.RequestId,
-		question,
-		properties.QueueNameAiOrchestratorCallback,
-		enumactions.Location,
-		0,
-	)
+	question, version, err := l.buildQuestion(*request.Context, *request.Research, "")
 	if err != nil {
 		slog.ErrorContext(ctx, "locationService.Execute",
 			slog.String("details", "process error"),
@@ -110,7 +181,14 @@ func (l locationService) Execute(ctx context.Context, request models.AiOrchestra
 		return err
 	}
 
-	err = l.queueGemini.Publish(ctx, b)
+	action := enumactions.Location
+	err = l.promptDispatcher.Dispatch(ctx, models.Prompt{
+		RequestId:       request.RequestId,
+		ResearchId:      request.ResearchId,
+		Action:          &action,
+		Question:        &question,
+		TemplateVersion: &version,
+	})
 	if err != nil {
 		slog.ErrorContext(ctx, "locationService.Execute",
 			slog.String("details", "process error"),
@@ -121,27 +199,32 @@ func (l locationService) Execute(ctx context.Context, request models.AiOrchestra
 	return nil
 }
 
-func (l locationService) Callback(ctx context.Context, callback models.AiOrchestratorCallbackRequest) error {
+func (l locationService) Callback(ctx context.Context, callback models.AiOrchestratorCallbackRequest) (string, error) {
 	slog.InfoContext(ctx, "locationService.Callback",
 		slog.String("details", "process started"))
 
-	locations := strings.Split(strings.ToLower(*callback.Response), ",")
-	errMessage := l.validateGeminiResponse(locations)
-	if errMessage != nil {
+	locations, errMessages := l.validateGeminiResponse(*callback.Response)
+	if errMessages != nil {
 		var request nosqlmodels.Request
 		err := l.orchestratorRepository.GetById(ctx, *callback.RequestId, &request)
 		if err != nil {
 			slog.ErrorContext(ctx, "locationService.Callback",
 				slog.String("details", "process error"),
 				slog.String("error", err.Error()))
-			return err
+			return "", err
+		}
+		question, version, err := l.buildQuestion(*request.Context, *request.Research, attemptVersion(callback))
+		if err != nil {
+			slog.ErrorContext(ctx, "locationService.Callback",
+				slog.String("details", "process error"),
+				slog.String("error", err.Error()))
+			return "", err
 		}
-		question := l.buildQuestion(*request.Context, *request.Research)
-		err = errortypes.NewInvalidAIResponseException(*request.ID, question, enumactions.Location, callback.ReceiveCount+1, *errMessage)
+		err = errortypes.NewInvalidAIResponseException(retryForward(callback, question, version), errMessages...)
 		slog.ErrorContext(ctx, "locationService.Callback",
 			slog.String("details", "process error"),
 			slog.String("error", err.Error()))
-		return err
+		return "", err
 	}
 
 	g, groupCtx := errgroup.WithContext(ctx)
@@ -160,7 +243,7 @@ func (l locationService) Callback(ctx context.Context, callback models.AiOrchest
 		slog.ErrorContext(ctx, "locationService.Callback",
 			slog.String("details", "process error"),
 			slog.String("error", err.Error()))
-		return err
+		return "", err
 	}
 
 	err = l.orchestratorRepository.Update(ctx, *callback.RequestId,
@@ -170,42 +253,21 @@ func (l locationService) Callback(ctx context.Context, callback models.AiOrchest
 		slog.ErrorContext(ctx, "locationService.Callback",
 			slog.String("details", "process error"),
 			slog.String("error", err.Error()))
-		return err
-	}
-
-	var (
-		b      []byte
-		action = enumactions.Language
-	)
-	b, err = builder.BuildQueueOrchestratorMessage(dtos.AiOrchestratorRequest{
-		RequestId: callback.RequestId,
-		Action:    &action,
-	})
-	if err != nil {
-		slog.ErrorContext(ctx, "locationService.Callback",
-			slog.String("details", "process error"),
-			slog.String("error", err.Error()))
-		return err
-	}
-
-	err = l.queueOrchestrator.Publish(ctx, b)
-	if err != nil {
-		slog.ErrorContext(ctx, "locationService.Callback",
-			slog.String("details", "process error"),
-			slog.String("error", err.Error()))
-		return err
+		return "", err
 	}
 
 	slog.InfoContext(ctx, "locationService.Callback",
 		slog.String("details", "process finished"))
-	return nil
+	return enumoutcomes.Done, nil
 }
 
-func NewLocationService(queueGemini, queueOrchestrator interfaces.Queue, orchestratorRepository interfaces.OrchestratorRepository, requestRepository interfaces.RequestRepository) interfaces.Service {
+func NewLocationService(promptDispatcher interfaces.PromptDispatcher, promptRegistry interfaces.PromptRegistry, responseParser interfaces.ResponseParser, acceptancePolicy acceptance.Policy, orchestratorRepository interfaces.OrchestratorRepository, requestRepository interfaces.RequestRepository) interfaces.Service {
 	return &locationService{
-		queueGemini:            queueGemini,
+		promptDispatcher:       promptDispatcher,
+		promptRegistry:         promptRegistry,
+		responseParser:         responseParser,
+		acceptancePolicy:       acceptancePolicy,
 		requestRepository:      requestRepository,
 		orchestratorRepository: orchestratorRepository,
-		queueOrchestrator:      queueOrchestrator,
 	}
 }
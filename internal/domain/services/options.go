@@ -0,0 +1,88 @@
+package services
+
+import (
+	"context"
+	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/interfaces"
+	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"slices"
+	"strconv"
+)
+
+const (
+	defaultSentenceCount = 5
+	defaultSummaryLength = "short"
+	defaultSummaryStyle  = "prose"
+)
+
+func sentenceCount(options *models.Options) int {
+	if options == nil || options.SentenceCount == nil {
+		return defaultSentenceCount
+	}
+	return *options.SentenceCount
+}
+
+func summaryLength(options *models.Options) string {
+	if options == nil || options.SummaryLength == nil {
+		return defaultSummaryLength
+	}
+	return *options.SummaryLength
+}
+
+func summaryStyle(options *models.Options) string {
+	if options == nil || options.SummaryStyle == nil {
+		return defaultSummaryStyle
+	}
+	return *options.SummaryStyle
+}
+
+// stageEnabled tells whether a request runs an optional stage, stages being
+// enabled unless turned off by the options.
+func stageEnabled(options *models.Options, action string) bool {
+	if options == nil {
+		return true
+	}
+	enabled, ok := options.Stages[action]
+	return !ok || enabled
+}
+
+// reserveSource takes one of the sources a request may summarize for the
+// research. Requests without a limit always have room, and a research keeps
+// the source it took, so redeliveries are given it again.
+func reserveSource(ctx context.Context, orchestratorRepository interfaces.OrchestratorRepository, request models.OrchestratorRequest, researchId string) (bool, error) {
+	if request.Options == nil || request.Options.MaxSources == nil {
+		return true, nil
+	}
+	if slices.Contains(request.SummarizedResearches, researchId) {
+		return true, nil
+	}
+
+	reserved, err := orchestratorRepository.UpdateOne(ctx,
+		bson.M{
+			"_id": *request.ID,
+			"summarizedResearches." + strconv.Itoa(*request.Options.MaxSources-1): bson.M{"$exists": false},
+		},
+		bson.M{"$addToSet": bson.M{"summarizedResearches": researchId}},
+	)
+	if err != nil || reserved {
+		return reserved, err
+	}
+
+	// The update also changes nothing when a concurrent delivery of the same
+	// research took the source first.
+	err = orchestratorRepository.GetById(ctx, *request.ID, &request)
+	if err != nil {
+		return false, err
+	}
+	return slices.Contains(request.SummarizedResearches, researchId), nil
+}
+
+// releaseSource gives back the source of a rejected research, e.g. one a
+// replay runs again, so one of the researches still to come may take it.
+func releaseSource(ctx context.Context, orchestratorRepository interfaces.OrchestratorRepository, requestId, researchId string) error {
+	_, err := orchestratorRepository.UpdateOne(ctx,
+		bson.M{"_id": requestId},
+		bson.M{"$pull": bson.M{"summarizedResearches": researchId}},
+	)
+	return err
+}
@@ -0,0 +1,111 @@
+package services
+
+import (
+	"context"
+	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/interfaces"
+	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// sourcesRepository keeps the summarized researches of a single request,
+// applying the updates reserveSource and releaseSource make.
+type sourcesRepository struct {
+	interfaces.OrchestratorRepository
+	summarized []string
+}
+
+func (r *sourcesRepository) GetById(_ context.Context, _ string, model interface{}) error {
+	model.(*models.OrchestratorRequest).SummarizedResearches = slices.Clone(r.summarized)
+	return nil
+}
+
+func (r *sourcesRepository) UpdateOne(_ context.Context, filter bson.M, update bson.M) (bool, error) {
+	for key := range filter {
+		index, ok := strings.CutPrefix(key, "summarizedResearches.")
+		if !ok {
+			continue
+		}
+		i, err := strconv.Atoi(index)
+		if err != nil {
+			return false, err
+		}
+		if i < len(r.summarized) {
+			return false, nil
+		}
+	}
+
+	if added, ok := update["$addToSet"].(bson.M); ok {
+		researchId := added["summarizedResearches"].(string)
+		if slices.Contains(r.summarized, researchId) {
+			return false, nil
+		}
+		r.summarized = append(r.summarized, researchId)
+		return true, nil
+	}
+
+	pulled := update["$pull"].(bson.M)["summarizedResearches"].(string)
+	n := len(r.summarized)
+	r.summarized = slices.DeleteFunc(r.summarized, func(researchId string) bool { return researchId == pulled })
+	return len(r.summarized) < n, nil
+}
+
+func TestReserveSource(t *testing.T) {
+	ctx := context.Background()
+	requestId, maxSources := "request", 2
+	repository := &sourcesRepository{}
+
+	reserve := func(researchId string) bool {
+		var request models.OrchestratorRequest
+		_ = repository.GetById(ctx, requestId, &request)
+		request.ID = &requestId
+		request.Options = &models.Options{MaxSources: &maxSources}
+
+		reserved, err := reserveSource(ctx, repository, request, researchId)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return reserved
+	}
+	release := func(researchId string) {
+		if err := releaseSource(ctx, repository, requestId, researchId); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Researches rejected before summarize never took a source, so they
+	// leave every source to the accepted ones.
+	release("rejected-1")
+	release("rejected-2")
+
+	steps := []struct {
+		name       string
+		researchId string
+		release    bool
+		want       bool
+	}{
+		{name: "first accepted", researchId: "accepted-1", want: true},
+		{name: "second accepted", researchId: "accepted-2", want: true},
+		{name: "redelivery keeps its source", researchId: "accepted-2", want: true},
+		{name: "past the cap", researchId: "accepted-3", want: false},
+		{name: "failed gives its source back", researchId: "accepted-1", release: true},
+		{name: "source given back is taken", researchId: "accepted-3", want: true},
+		{name: "past the cap again", researchId: "accepted-4", want: false},
+	}
+	for _, step := range steps {
+		if step.release {
+			release(step.researchId)
+			continue
+		}
+		if got := reserve(step.researchId); got != step.want {
+			t.Errorf("%s: reserveSource(%s) = %v, want %v", step.name, step.researchId, got, step.want)
+		}
+	}
+
+	if want := []string{"accepted-2", "accepted-3"}; !slices.Equal(repository.summarized, want) {
+		t.Errorf("summarized researches = %v, want %v", repository.summarized, want)
+	}
+}
@@ -0,0 +1,262 @@
+package services
+
+import (
+	"context"
+	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/config/errortypes"
+	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/builder"
+	enumactions "github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/enums/actions"
+	enumoutcomes "github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/enums/outcomes"
+	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/interfaces"
+	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/models"
+	nosqlmodels "github.com/PesquisAi/pesquisai-database-lib/nosql/models"
+	enumstatus "github.com/PesquisAi/pesquisai-database-lib/sql/enums/status"
+	"go.mongodb.org/mongo-driver/bson"
+	"log/slog"
+	"strings"
+)
+
+type overallSource struct {
+	Number  int
+	Title   string
+	Link    string
+	Summary string
+}
+
+type overallQuestion struct {
+	Context        string
+	Research       string
+	Sources        []overallSource
+	OutputLanguage string
+}
+
+type overallAnswer struct {
+	Report string `json:"report"`
+}
+
+type overallService struct {
+	queueStatusManager     interfaces.Queue
+	promptDispatcher       interfaces.PromptDispatcher
+	promptRegistry         interfaces.PromptRegistry
+	responseParser         interfaces.ResponseParser
+	orchestratorRepository interfaces.OrchestratorRepository
+}
+
+func (l overallService) validateOrchestratorData(request models.OrchestratorRequest) error {
+	var messages []string
+	if request.Context == nil {
+		messages = append(messages, `"context" is required in mongoDB to perform overall service`)
+	}
+	if request.Research == nil {
+		messages = append(messages, `"research" is required in mongoDB to perform overall service`)
+	}
+	if request.CompletedAt == nil {
+		messages = append(messages, `"completedAt" is required in mongoDB to perform overall service, researches are not finished yet`)
+	}
+	if len(messages) > 0 {
+		return errortypes.NewValidationException(messages...)
+	}
+	return nil
+}
+
+func (l overallService) validateGeminiResponse(response, version string) (string, []string) {
+	var answer overallAnswer
+	errorMessages := l.responseParser.Parse(enumactions.Overall, response, &answer)
+	if errorMessages != nil {
+		return "", errorMessages
+	}
+
+	if strings.TrimSpace(answer.Report) == "" {
+		return "", []string{"overall report is empty"}
+	}
+	if echoesInstructions(l.promptRegistry, answer.Report, version, enumactions.Overall) {
+		return "", []string{echoMessage}
+	}
+	return answer.Report, nil
+}
+
+func (l overallService) buildSources(ctx context.Context, request models.OrchestratorRequest) ([]overallSource, error) {
+	var sources []overallSource
+	for _, researchId := range request.FinishedResearches {
+		var research nosqlmodels.Research
+		err := l.orchestratorRepository.GetById(ctx, researchId, &research)
+		if err != nil {
+			return nil, err
+		}
+		if research.Summary == nil || research.Title == nil || research.Link == nil {
+			continue
+		}
+
+		sources = append(sources, overallSource{
+			Number:  len(sources) + 1,
+			Title:   *research.Title,
+			Link:    *research.Link,
+			Summary: *research.Summary,
+		})
+	}
+	return sources, nil
+}
+
+func (l overallService) buildQuestion(ctx context.Context, request models.OrchestratorRequest, templateVersion string) (question, version string, sources int, err error) {
+	err = l.validateOrchestratorData(request)
+	if err != nil {
+		slog.ErrorContext(ctx, "overallService.buildQuestion",
+			slog.String("details", "process error"),
+			slog.String("error", err.Error()))
+		return
+	}
+
+	summaries, err := l.buildSources(ctx, request)
+	if err != nil {
+		slog.ErrorContext(ctx, "overallService.buildQuestion",
+			slog.String("details", "process error"),
+			slog.String("error", err.Error()))
+		return
+	}
+
+	question, version, err = l.promptRegistry.Render(enumactions.Overall, templateVersion, overallQuestion{
+		Context:        *request.Context,
+		Research:       *request.Research,
+		Sources:        summaries,
+		OutputLanguage: outputLanguage(request),
+	})
+	return question, version, len(summaries), err
+}
+
+func (l overallService) publishStatus(ctx context.Context, requestId string, overall *string) error {
+	b, err := builder.BuildQueueStatusManagerOverallMessage(requestId, enumstatus.FINISHED, overall)
+	if err != nil {
+		return err
+	}
+
+	return l.queueStatusManager.Publish(ctx, b)
+}
+
+func (l overallService) Execute(ctx context.Context, orchestratorRequest models.AiOrchestratorRequest) error {
+	slog.InfoContext(ctx, "overallService.Execute",
+		slog.String("details", "process started"))
+
+	var request models.OrchestratorRequest
+	err := l.orchestratorRepository.GetById(ctx, *orchestratorRequest.RequestId, &request)
+	if err != nil {
+		slog.ErrorContext(ctx, "overallService.Execute",
+			slog.String("details", "process error"),
+			slog.String("error", err.Error()))
+		return err
+	}
+
+	if !stageEnabled(request.Options, enumactions.Overall) {
+		slog.InfoContext(ctx, "overallService.Execute",
+			slog.String("details", "stage disabled, finishing request without overall"))
+
+		err = l.publishStatus(ctx, *orchestratorRequest.RequestId, nil)
+		if err != nil {
+			slog.ErrorContext(ctx, "overallService.Execute",
+				slog.String("details", "process error"),
+				slog.String("error", err.Error()))
+			return err
+		}
+		return nil
+	}
+
+	question, version, sources, err := l.buildQuestion(ctx, request, "")
+	if err != nil {
+		slog.ErrorContext(ctx, "overallService.Execute",
+			slog.String("details", "process error"),
+			slog.String("error", err.Error()))
+		return err
+	}
+
+	if sources == 0 {
+		slog.WarnContext(ctx, "overallService.Execute",
+			slog.String("details", "no summarized research, finishing request without overall"))
+
+		err = l.publishStatus(ctx, *orchestratorRequest.RequestId, nil)
+		if err != nil {
+			slog.ErrorContext(ctx, "overallService.Execute",
+				slog.String("details", "process error"),
+				slog.String("error", err.Error()))
+			return err
+		}
+		return nil
+	}
+
+	action := enumactions.Overall
+	err = l.promptDispatcher.Dispatch(ctx, models.Prompt{
+		RequestId:       orchestratorRequest.RequestId,
+		ResearchId:      orchestratorRequest.ResearchId,
+		Action:          &action,
+		Question:        &question,
+		TemplateVersion: &version,
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "overallService.Execute",
+			slog.String("details", "process error"),
+			slog.String("error", err.Error()))
+		return err
+	}
+
+	return nil
+}
+
+func (l overallService) Callback(ctx context.Context, callback models.AiOrchestratorCallbackRequest) (string, error) {
+	slog.InfoContext(ctx, "overallService.Callback",
+		slog.String("details", "process started"))
+
+	report, errMessages := l.validateGeminiResponse(*callback.Response, attemptVersion(callback))
+	if errMessages != nil {
+		var request models.OrchestratorRequest
+		err := l.orchestratorRepository.GetById(ctx, *callback.RequestId, &request)
+		if err != nil {
+			slog.ErrorContext(ctx, "overallService.Callback",
+				slog.String("details", "process error"),
+				slog.String("error", err.Error()))
+			return "", err
+		}
+
+		question, version, _, err := l.buildQuestion(ctx, request, attemptVersion(callback))
+		if err != nil {
+			slog.ErrorContext(ctx, "overallService.Callback",
+				slog.String("details", "process error"),
+				slog.String("error", err.Error()))
+			return "", err
+		}
+
+		err = errortypes.NewInvalidAIResponseException(retryForward(callback, question, version), errMessages...)
+		slog.ErrorContext(ctx, "overallService.Callback",
+			slog.String("details", "process error"),
+			slog.String("error", err.Error()))
+		return "", err
+	}
+
+	err := l.orchestratorRepository.Update(ctx, *callback.RequestId,
+		bson.M{"overall": report},
+	)
+	if err != nil {
+		slog.ErrorContext(ctx, "overallService.Callback",
+			slog.String("details", "process error"),
+			slog.String("error", err.Error()))
+		return "", err
+	}
+
+	err = l.publishStatus(ctx, *callback.RequestId, &report)
+	if err != nil {
+		slog.ErrorContext(ctx, "overallService.Callback",
+			slog.String("details", "process error"),
+			slog.String("error", err.Error()))
+		return "", err
+	}
+
+	slog.InfoContext(ctx, "overallService.Callback",
+		slog.String("details", "process finished"))
+	return enumoutcomes.Done, nil
+}
+
+func NewOverallService(promptDispatcher interfaces.PromptDispatcher, promptRegistry interfaces.PromptRegistry, responseParser interfaces.ResponseParser, queueStatusManager interfaces.Queue, orchestratorRepository interfaces.OrchestratorRepository) interfaces.Service {
+	return &overallService{
+		queueStatusManager:     queueStatusManager,
+		promptDispatcher:       promptDispatcher,
+		promptRegistry:         promptRegistry,
+		responseParser:         responseParser,
+		orchestratorRepository: orchestratorRepository,
+	}
+}
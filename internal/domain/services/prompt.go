@@ -0,0 +1,73 @@
+package services
+
+import (
+	"context"
+	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/interfaces"
+	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/language"
+	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/models"
+)
+
+// retryForward is the forward of the invalid answer to a callback: the prompt
+// to send again, counting as the next attempt.
+func retryForward(callback models.AiOrchestratorCallbackRequest, question, version string) map[string]any {
+	forward := map[string]any{
+		"requestId":       *callback.RequestId,
+		"question":        question,
+		"action":          *callback.Action,
+		"templateVersion": version,
+		"receiveCount":    callback.ReceiveCount + 1,
+	}
+	if callback.ResearchId != nil {
+		forward["researchId"] = *callback.ResearchId
+	}
+	if callback.Chunk != nil {
+		forward["chunk"] = *callback.Chunk
+	}
+	if callback.Voter != nil {
+		forward["voter"] = *callback.Voter
+	}
+	return forward
+}
+
+// attemptVersion is the template version the answered prompt was rendered
+// with, so its retry asks the same question. It is empty, for the active
+// version, when the callback does not tell it.
+func attemptVersion(callback models.AiOrchestratorCallbackRequest) string {
+	if callback.TemplateVersion == nil {
+		return ""
+	}
+	return *callback.TemplateVersion
+}
+
+// dispatchDecision sends the prompt of a yes/no decision, once to every voter
+// of the ballot when the decision is voted.
+func dispatchDecision(ctx context.Context, promptDispatcher interfaces.PromptDispatcher, ballot interfaces.Ballot, prompt models.Prompt) error {
+	voters := len(ballot.Voters())
+	if voters == 0 {
+		return promptDispatcher.Dispatch(ctx, prompt)
+	}
+
+	err := ballot.Open(ctx, *prompt.ResearchId)
+	if err != nil {
+		return err
+	}
+
+	for i := 0; i < voters; i++ {
+		voter := i
+		prompt.Voter = &voter
+		err = promptDispatcher.Dispatch(ctx, prompt)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// outputLanguage is the language code the summaries and reports of a request
+// are written in. Requests created before it was stored have it detected.
+func outputLanguage(request models.OrchestratorRequest) string {
+	if request.OutputLanguage != nil {
+		return *request.OutputLanguage
+	}
+	return language.Detect(*request.Context + "\n" + *request.Research)
+}
@@ -0,0 +1,73 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/interfaces"
+	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"time"
+)
+
+// errUndecidable is returned by judge when every voter of the ensemble
+// abstained, so the research cannot be decided.
+var errUndecidable = errors.New("every voter abstained")
+
+// worthAnswer is the answer of the prompts rating how relevant a web page is
+// to the research, from 0 to 100.
+type worthAnswer struct {
+	Score  int    `json:"score"`
+	Reason string `json:"reason"`
+}
+
+// judge decides whether a research goes further given the score of an answer
+// and the threshold of the request for the action, defaultThreshold when the
+// request sets none. Voted answers are cast on the ballot and judged by its
+// decision, an abstained callback being an abstention of its voter. Once
+// decided, the score and its reason are kept on the research.
+func judge(ctx context.Context, orchestratorRepository interfaces.OrchestratorRepository, ballot interfaces.Ballot, defaultThreshold int, callback models.AiOrchestratorCallbackRequest, answer worthAnswer) (decided bool, worth bool, err error) {
+	var request models.OrchestratorRequest
+	err = orchestratorRepository.GetById(ctx, *callback.RequestId, &request)
+	if err != nil {
+		return
+	}
+
+	threshold := defaultThreshold
+	if t, ok := request.Thresholds[*callback.Action]; ok {
+		threshold = t
+	}
+
+	score, worth, reason := answer.Score, answer.Score >= threshold, &answer.Reason
+	if callback.Voter != nil {
+		var decision models.Decision
+		if callback.Abstained {
+			reason = nil
+			decided, decision, err = ballot.Abstain(ctx, *callback.ResearchId, *callback.Voter)
+		} else {
+			decided, decision, err = ballot.Cast(ctx, *callback.ResearchId, *callback.Voter, models.Vote{
+				Score:  answer.Score,
+				Reason: &answer.Reason,
+				Worth:  worth,
+			})
+		}
+		if err != nil || !decided {
+			return
+		}
+		if decision.Yes+decision.No == 0 {
+			return false, false, errUndecidable
+		}
+		score, worth = decision.Score, decision.Worth
+	}
+
+	now := time.Now().UTC()
+	err = orchestratorRepository.Update(ctx, *callback.ResearchId, bson.M{
+		"relevance." + *callback.Action: models.Relevance{
+			Score:     score,
+			Reason:    reason,
+			Threshold: threshold,
+			Worth:     worth,
+			CreatedAt: &now,
+		},
+	})
+	return err == nil, worth, err
+}
@@ -0,0 +1,193 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/config/errortypes"
+	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/delivery/dtos"
+	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/builder"
+	enumactions "github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/enums/actions"
+	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/interfaces"
+	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/models"
+	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/pipeline"
+	nosqlmodels "github.com/PesquisAi/pesquisai-database-lib/nosql/models"
+	"log/slog"
+)
+
+// replayService re-runs a single stage of a request from the state already
+// stored in the orchestrator collection.
+type replayService struct {
+	queueOrchestrator      interfaces.Queue
+	orchestratorRepository interfaces.OrchestratorRepository
+	pipeline               pipeline.Pipeline
+}
+
+func (l replayService) validateOrchestratorRequest(request models.AiOrchestratorRequest) error {
+	var messages []string
+	if request.ReplayAction == nil {
+		messages = append(messages, `"replay_action" is required to perform replay service`)
+	} else if !l.pipeline.Has(*request.ReplayAction) {
+		messages = append(messages, fmt.Sprintf(`"%s" is not a pipeline stage that can be replayed`, *request.ReplayAction))
+	} else if *request.ReplayAction == enumactions.Location {
+		messages = append(messages, `"location" creates the request and can not be replayed`)
+	} else if *request.ReplayAction == enumactions.SummarizeChunk {
+		messages = append(messages, `"summarize-chunk" is started by worth-summarize and summarize, replay one of them instead`)
+	}
+	if len(messages) > 0 {
+		return errortypes.NewValidationException(messages...)
+	}
+	return nil
+}
+
+func (l replayService) validateRequestState(action string, request models.OrchestratorRequest) error {
+	var messages []string
+	if request.Context == nil {
+		messages = append(messages, `"context" is required in mongoDB to replay the request`)
+	}
+	if request.Research == nil {
+		messages = append(messages, `"research" is required in mongoDB to replay the request`)
+	}
+
+	switch action {
+	case enumactions.Language:
+		if request.Locations == nil {
+			messages = append(messages, `"locations" is required in mongoDB to replay language`)
+		}
+	case enumactions.Sentences:
+		if request.Languages == nil {
+			messages = append(messages, `"languages" is required in mongoDB to replay sentences`)
+		}
+	case enumactions.Overall:
+		if request.CompletedAt == nil {
+			messages = append(messages, `"completedAt" is required in mongoDB to replay overall`)
+		}
+	}
+
+	if len(messages) > 0 {
+		return errortypes.NewValidationException(messages...)
+	}
+	return nil
+}
+
+func (l replayService) validateResearchState(action string, researchId *string, research nosqlmodels.Research) error {
+	var messages []string
+	switch action {
+	case enumactions.WorthAccessing:
+		if research.Title == nil || research.Link == nil {
+			messages = append(messages, fmt.Sprintf(`"title" and "link" are required in research "%s" to replay %s`, *researchId, action))
+		}
+	case enumactions.WorthSummarize, enumactions.Summarize:
+		if research.Content == nil {
+			messages = append(messages, fmt.Sprintf(`"content" is required in research "%s" to replay %s`, *researchId, action))
+		}
+	}
+
+	if len(messages) > 0 {
+		return errortypes.NewValidationException(messages...)
+	}
+	return nil
+}
+
+func (l replayService) isResearchAction(action string) bool {
+	return action == enumactions.WorthAccessing ||
+		action == enumactions.WorthSummarize ||
+		action == enumactions.Summarize
+}
+
+func (l replayService) validateState(ctx context.Context, orchestratorRequest models.AiOrchestratorRequest) error {
+	action := *orchestratorRequest.ReplayAction
+
+	var request models.OrchestratorRequest
+	err := l.orchestratorRepository.GetById(ctx, *orchestratorRequest.RequestId, &request)
+	if err != nil {
+		return err
+	}
+
+	err = l.validateRequestState(action, request)
+	if err != nil {
+		return err
+	}
+
+	if !l.isResearchAction(action) {
+		return nil
+	}
+
+	if orchestratorRequest.ResearchId == nil {
+		return errortypes.NewValidationException(fmt.Sprintf(`"research_id" is required to replay %s`, action))
+	}
+
+	var research nosqlmodels.Research
+	err = l.orchestratorRepository.GetById(ctx, *orchestratorRequest.ResearchId, &research)
+	if err != nil {
+		return err
+	}
+
+	return l.validateResearchState(action, orchestratorRequest.ResearchId, research)
+}
+
+func (l replayService) Execute(ctx context.Context, orchestratorRequest models.AiOrchestratorRequest) error {
+	slog.InfoContext(ctx, "replayService.Execute",
+		slog.String("details", "process started"))
+
+	err := l.validateOrchestratorRequest(orchestratorRequest)
+	if err != nil {
+		slog.ErrorContext(ctx, "replayService.Execute",
+			slog.String("details", "process error"),
+			slog.String("error", err.Error()))
+		return err
+	}
+
+	err = l.validateState(ctx, orchestratorRequest)
+	if err != nil {
+		slog.ErrorContext(ctx, "replayService.Execute",
+			slog.String("details", "process error"),
+			slog.String("error", err.Error()))
+		return err
+	}
+
+	var researchId *string
+	if l.isResearchAction(*orchestratorRequest.ReplayAction) {
+		researchId = orchestratorRequest.ResearchId
+	}
+
+	b, err := builder.BuildQueueOrchestratorMessage(dtos.AiOrchestratorRequest{
+		RequestId:  orchestratorRequest.RequestId,
+		ResearchId: researchId,
+		Action:     orchestratorRequest.ReplayAction,
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "replayService.Execute",
+			slog.String("details", "process error"),
+			slog.String("error", err.Error()))
+		return err
+	}
+
+	err = l.queueOrchestrator.Publish(ctx, b)
+	if err != nil {
+		slog.ErrorContext(ctx, "replayService.Execute",
+			slog.String("details", "process error"),
+			slog.String("error", err.Error()))
+		return err
+	}
+
+	slog.InfoContext(ctx, "replayService.Execute",
+		slog.String("details", "process finished"),
+		slog.String("replayAction", *orchestratorRequest.ReplayAction))
+	return nil
+}
+
+func (l replayService) Callback(ctx context.Context, _ models.AiOrchestratorCallbackRequest) (string, error) {
+	err := errortypes.NewValidationException(`"replay" does not expect an AI callback`)
+	slog.ErrorContext(ctx, "replayService.Callback",
+		slog.String("details", "process error"),
+		slog.String("error", err.Error()))
+	return "", err
+}
+
+func NewReplayService(queueOrchestrator interfaces.Queue, orchestratorRepository interfaces.OrchestratorRepository, pipeline pipeline.Pipeline) interfaces.Service {
+	return &replayService{
+		queueOrchestrator:      queueOrchestrator,
+		orchestratorRepository: orchestratorRepository,
+		pipeline:               pipeline,
+	}
+}
@@ -2,42 +2,65 @@ package services
 
 import (
 	"context"
-	"fmt"
 	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/config/errortypes"
-	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/config/properties"
 	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/builder"
 	enumactions "github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/enums/actions"
+	enumoutcomes "github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/enums/outcomes"
 	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/interfaces"
 	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/models"
+	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/sentences"
 	nosqlmodels "github.com/PesquisAi/pesquisai-database-lib/nosql/models"
 	"go.mongodb.org/mongo-driver/bson"
 	"log/slog"
 	"strings"
 )
 
-const (
-	sentenceQuestionTemplate = `You are a part of a major project. In this project I will perform a google search, and your only` +
-		` responsibility is to answer me, given the context of the pearson/company that are asking and the research they want to do` +
-		`, what are the %d best sentences that should be used to perform the Google search? ` +
-		`Respond only the a sentences list, NOTHING else! This list NEEDS to be a \n separated list Ex: sentence1 \n sentence2 \n sentence3... !` +
-		`Do not enumerate the list. When possible, use a different language to each sentence. ` +
-		`person/company context:"%s". research:"%s". languages:"%s"`
-	sentenceAmount = 5
-)
+type sentenceQuestion struct {
+	SentenceAmount int
+	Context        string
+	Research       string
+	Languages      string
+	Locations      string
+}
+
+type sentenceEntry struct {
+	Sentence string `json:"sentence"`
+	Language string `json:"language"`
+	Country  string `json:"country"`
+}
+
+type sentenceAnswer struct {
+	Sentences []sentenceEntry `json:"sentences"`
+}
 
 type sentenceService struct {
-	queueGemini            interfaces.Queue
+	promptDispatcher       interfaces.PromptDispatcher
+	promptRegistry         interfaces.PromptRegistry
+	responseParser         interfaces.ResponseParser
 	queueGoogleSearch      interfaces.Queue
 	orchestratorRepository interfaces.OrchestratorRepository
 }
 
-func (l sentenceService) validateGeminiResponse(response string) ([]string, *string) {
-	split := strings.Split(strings.ToLower(response), "\n")
-	if len(split) == 0 {
-		message := fmt.Sprintf("sentences with wrong format '%s'", response)
-		return nil, &message
+func (l sentenceService) validateGeminiResponse(response string, request models.OrchestratorRequest) ([]models.Sentence, []string) {
+	var answer sentenceAnswer
+	errorMessages := l.responseParser.Parse(enumactions.Sentences, response, &answer)
+	if errorMessages != nil {
+		return nil, errorMessages
+	}
+
+	entries := make([]models.Sentence, len(answer.Sentences))
+	for i, entry := range answer.Sentences {
+		entries[i] = models.Sentence{Sentence: entry.Sentence, Language: entry.Language, Country: entry.Country}
+	}
+
+	var languages, locations []string
+	if request.Languages != nil {
+		languages = *request.Languages
+	}
+	if request.Locations != nil {
+		locations = *request.Locations
 	}
-	return split, nil
+	return sentences.Normalize(entries, sentenceCount(request.Options), languages, locations)
 }
 func (l sentenceService) validateOrchestratorData(request nosqlmodels.Request) error {
 	var messages []string
@@ -59,21 +82,21 @@ func (l sentenceService) validateOrchestratorData(request nosqlmodels.Request) e
 	return nil
 }
 
-func (l sentenceService) buildQuestion(request nosqlmodels.Request) string {
-	return fmt.Sprintf(
-		sentenceQuestionTemplate,
-		sentenceAmount,
-		*request.Context,
-		*request.Research,
-		strings.Join(*request.Languages, ","),
-	)
+func (l sentenceService) buildQuestion(request models.OrchestratorRequest, templateVersion string) (question, version string, err error) {
+	return l.promptRegistry.Render(enumactions.Sentences, templateVersion, sentenceQuestion{
+		SentenceAmount: sentenceCount(request.Options),
+		Context:        *request.Context,
+		Research:       *request.Research,
+		Languages:      strings.Join(*request.Languages, ","),
+		Locations:      strings.Join(*request.Locations, ","),
+	})
 }
 
 func (l sentenceService) Execute(ctx context.Context, orchestratorRequest models.AiOrchestratorRequest) error {
 	slog.InfoContext(ctx, "sentenceService.Execute",
 		slog.String("details", "process started"))
 
-	var request nosqlmodels.Request
+	var request models.OrchestratorRequest
 	err := l.orchestratorRepository.GetById(ctx, *orchestratorRequest.RequestId, &request)
 	if err != nil {
 		slog.ErrorContext(ctx, "sentenceService.Execute",
@@ -82,7 +105,7 @@ func (l sentenceService) Execute(ctx context.Context, orchestratorRequest models
 		return err
 	}
 
-	err = l.validateOrchestratorData(request)
+	err = l.validateOrchestratorData(request.Request)
 	if err != nil {
 		slog.ErrorContext(ctx, "sentenceService.Execute",
 			slog.String("details", "process error"),
@@ -90,15 +113,7 @@ func (l sentenceService) Execute(ctx context.Context, orchestratorRequest models
 		return err
 	}
 
-	question := l.buildQuestion(request)
-
-	b, err := builder.BuildQueueGeminiMessage(
-		*orchestratorRequest.RequestId,
-		question,
-		properties.QueueNameAiOrchestratorCallback,
-		enumactions.Sentences,
-		0,
-	)
+	question, version, err := l.buildQuestion(request, "")
 	if err != nil {
 		slog.ErrorContext(ctx, "sentenceService.Execute",
 			slog.String("details", "process error"),
@@ -106,7 +121,14 @@ func (l sentenceService) Execute(ctx context.Context, orchestratorRequest models
 		return err
 	}
 
-	err = l.queueGemini.Publish(ctx, b)
+	action := enumactions.Sentences
+	err = l.promptDispatcher.Dispatch(ctx, models.Prompt{
+		RequestId:       orchestratorRequest.RequestId,
+		ResearchId:      orchestratorRequest.ResearchId,
+		Action:          &action,
+		Question:        &question,
+		TemplateVersion: &version,
+	})
 	if err != nil {
 		slog.ErrorContext(ctx, "sentenceService.Execute",
 			slog.String("details", "process error"),
@@ -117,45 +139,57 @@ func (l sentenceService) Execute(ctx context.Context, orchestratorRequest models
 	return nil
 }
 
-func (l sentenceService) Callback(ctx context.Context, callback models.AiOrchestratorCallbackRequest) error {
+func (l sentenceService) Callback(ctx context.Context, callback models.AiOrchestratorCallbackRequest) (string, error) {
 	slog.InfoContext(ctx, "sentenceService.Callback",
 		slog.String("details", "process started"))
 
-	sentences, errMessage := l.validateGeminiResponse(*callback.Response)
-	if errMessage != nil {
-		var request nosqlmodels.Request
-		err := l.orchestratorRepository.GetById(ctx, *callback.RequestId, &request)
+	var request models.OrchestratorRequest
+	err := l.orchestratorRepository.GetById(ctx, *callback.RequestId, &request)
+	if err != nil {
+		slog.ErrorContext(ctx, "sentenceService.Callback",
+			slog.String("details", "process error"),
+			slog.String("error", err.Error()))
+		return "", err
+	}
+
+	tagged, errMessages := l.validateGeminiResponse(*callback.Response, request)
+	if errMessages != nil {
+		question, version, err := l.buildQuestion(request, attemptVersion(callback))
 		if err != nil {
 			slog.ErrorContext(ctx, "sentenceService.Callback",
 				slog.String("details", "process error"),
 				slog.String("error", err.Error()))
-			return err
+			return "", err
 		}
-		question := l.buildQuestion(request)
-		err = errortypes.NewInvalidAIResponseException(*callback.RequestId, question, enumactions.Sentences, callback.ReceiveCount+1, *errMessage)
+		err = errortypes.NewInvalidAIResponseException(retryForward(callback, question, version), errMessages...)
 		slog.ErrorContext(ctx, "sentenceService.Callback",
 			slog.String("details", "process error"),
 			slog.String("error", err.Error()))
-		return err
+		return "", err
 	}
 
-	err := l.orchestratorRepository.Update(ctx, *callback.RequestId,
-		bson.M{"sentences": sentences},
+	plain := make([]string, len(tagged))
+	for i, sentence := range tagged {
+		plain[i] = sentence.Sentence
+	}
+
+	err = l.orchestratorRepository.Update(ctx, *callback.RequestId,
+		bson.M{"sentences": plain},
 	)
 	if err != nil {
 		slog.ErrorContext(ctx, "sentenceService.Callback",
 			slog.String("details", "process error"),
 			slog.String("error", err.Error()))
-		return err
+		return "", err
 	}
 
 	var b []byte
-	b, err = builder.BuildQueueGoogleSearchMessage(*callback.RequestId)
+	b, err = builder.BuildQueueGoogleSearchMessage(*callback.RequestId, tagged)
 	if err != nil {
 		slog.ErrorContext(ctx, "sentenceService.Callback",
 			slog.String("details", "process error"),
 			slog.String("error", err.Error()))
-		return err
+		return "", err
 	}
 
 	err = l.queueGoogleSearch.Publish(ctx, b)
@@ -163,16 +197,18 @@ func (l sentenceService) Callback(ctx context.Context, callback models.AiOrchest
 		slog.ErrorContext(ctx, "sentenceService.Callback",
 			slog.String("details", "process error"),
 			slog.String("error", err.Error()))
-		return err
+		return "", err
 	}
 
 	slog.InfoContext(ctx, "sentenceService.Callback",
 		slog.String("details", "process finished"))
-	return nil
+	return enumoutcomes.Done, nil
 }
-func NewSentenceService(queueGemini, queueGoogleSearch interfaces.Queue, orchestratorRepository interfaces.OrchestratorRepository) interfaces.Service {
+func NewSentenceService(promptDispatcher interfaces.PromptDispatcher, promptRegistry interfaces.PromptRegistry, responseParser interfaces.ResponseParser, queueGoogleSearch interfaces.Queue, orchestratorRepository interfaces.OrchestratorRepository) interfaces.Service {
 	return &sentenceService{
-		queueGemini:            queueGemini,
+		promptDispatcher:       promptDispatcher,
+		promptRegistry:         promptRegistry,
+		responseParser:         responseParser,
 		orchestratorRepository: orchestratorRepository,
 		queueGoogleSearch:      queueGoogleSearch,
 	}
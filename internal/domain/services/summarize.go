@@ -4,9 +4,10 @@ import (
 	"context"
 	"fmt"
 	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/config/errortypes"
-	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/config/properties"
 	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/builder"
+	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/chunking"
 	enumactions "github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/enums/actions"
+	enumoutcomes "github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/enums/outcomes"
 	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/interfaces"
 	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/models"
 	nosqlmodels "github.com/PesquisAi/pesquisai-database-lib/nosql/models"
@@ -14,19 +15,42 @@ import (
 	"log/slog"
 )
 
-const (
-	summarizeQuestionTemplate = "You are a part of a major project that performs researches for business and you have one responsibility." +
-		" To summarize the content of a webpage given the research purpose . You will receive a context about the " +
-		"researcher and the research. Answer only with the summary and nothing else. Make the summary relatively short.\n" +
-		"researcher context:%s\n" +
-		"research:%s\n" +
-		"Web page content:%s"
-)
+// contentQuestion is the data of the prompts about the content of a web page.
+type contentQuestion struct {
+	Context        string
+	Research       string
+	Content        string
+	OutputLanguage string
+	SummaryLength  string
+	SummaryStyle   string
+}
+
+const summarizeReduceTemplate = "summarize-reduce"
+
+type summarizeAnswer struct {
+	Summary string `json:"summary"`
+}
 
 type summarizeService struct {
 	queueStatusManager     interfaces.Queue
-	queueGemini            interfaces.Queue
+	promptDispatcher       interfaces.PromptDispatcher
+	promptRegistry         interfaces.PromptRegistry
+	responseParser         interfaces.ResponseParser
 	orchestratorRepository interfaces.OrchestratorRepository
+	researchTracker        interfaces.ResearchTracker
+	chunkSummarizer        interfaces.ChunkSummarizer
+}
+
+func (l summarizeService) validateGeminiResponse(response, version string) (string, []string) {
+	var answer summarizeAnswer
+	errorMessages := l.responseParser.Parse(enumactions.Summarize, response, &answer)
+	if errorMessages != nil {
+		return "", errorMessages
+	}
+	if echoesInstructions(l.promptRegistry, answer.Summary, version, enumactions.Summarize, summarizeReduceTemplate) {
+		return "", []string{echoMessage}
+	}
+	return answer.Summary, nil
 }
 
 func (l summarizeService) validateOrchestratorData(request nosqlmodels.Request) error {
@@ -75,55 +99,139 @@ func (l summarizeService) validateResearch(research nosqlmodels.Research) error
 	return nil
 }
 
-func (l summarizeService) buildQuestion(ctx context.Context, requestId, researchId string) (question string, err error) {
+// render renders the question of the research from its content, or from the
+// summaries of its chunks when it was summarized by chunks. It has no side
+// effect, so the retry of an attempt renders it again.
+func (l summarizeService) render(request models.OrchestratorRequest, research models.OrchestratorResearch, summaries []string, templateVersion string) (question, version string, err error) {
+	err = l.validateOrchestratorData(request.Request)
+	if err != nil {
+		return
+	}
+
+	// Content summarized by chunks is reduced to a single summary.
+	if summaries != nil {
+		return l.promptRegistry.Render(summarizeReduceTemplate, templateVersion, contentQuestion{
+			Context:        *request.Context,
+			Research:       *request.Research,
+			Content:        chunking.Digest(summaries),
+			OutputLanguage: outputLanguage(request),
+			SummaryLength:  summaryLength(request.Options),
+			SummaryStyle:   summaryStyle(request.Options),
+		})
+	}
+
+	return l.promptRegistry.Render(enumactions.Summarize, templateVersion, contentQuestion{
+		Context:        *request.Context,
+		Research:       *request.Research,
+		Content:        *research.Content,
+		OutputLanguage: outputLanguage(request),
+		SummaryLength:  summaryLength(request.Options),
+		SummaryStyle:   summaryStyle(request.Options),
+	})
+}
 
-	var research nosqlmodels.Research
-	err = l.orchestratorRepository.GetById(ctx, researchId, &research)
+// buildQuestion prepares the content of the research, whose chunks are
+// summarized first when it does not fit a prompt, and renders its question
+// once ready.
+func (l summarizeService) buildQuestion(ctx context.Context, request models.OrchestratorRequest, research models.OrchestratorResearch) (question, version string, ready bool, err error) {
+	err = l.validateOrchestratorData(request.Request)
 	if err != nil {
-		slog.ErrorContext(ctx, "summarizeService.Execute",
-			slog.String("details", "process error"),
-			slog.String("error", err.Error()))
 		return
 	}
 
-	err = l.validateResearch(research)
+	summaries, ready, err := l.chunkSummarizer.Prepare(ctx, request.Request, research, enumactions.Summarize)
+	if err != nil || !ready {
+		return
+	}
+
+	question, version, err = l.render(request, research, summaries, "")
+	return
+}
+
+// retryQuestion renders the question of an attempt again, from the content
+// already prepared for it.
+func (l summarizeService) retryQuestion(request models.OrchestratorRequest, research models.OrchestratorResearch, templateVersion string) (string, string, error) {
+	err := l.validateResearch(research.Research)
+	if err != nil {
+		return "", "", err
+	}
+
+	summaries, ready := l.chunkSummarizer.Summaries(research)
+	if !ready {
+		return "", "", fmt.Errorf("content of research %s is not prepared, its question can not be rendered again", *research.ID)
+	}
+	return l.render(request, research, summaries, templateVersion)
+}
+
+// finish ends a research that is not summarized.
+func (l summarizeService) finish(ctx context.Context, requestId, researchId string) error {
+	b, err := builder.BuildQueueStatusManagerMessage(nil, &researchId, enumstatus.FINISHED)
+	if err != nil {
+		return err
+	}
+
+	err = l.queueStatusManager.Publish(ctx, b)
+	if err != nil {
+		return err
+	}
+	return l.researchTracker.Finish(ctx, requestId, researchId)
+}
+
+func (l summarizeService) Execute(ctx context.Context, orchestratorRequest models.AiOrchestratorRequest) error {
+	slog.InfoContext(ctx, "summarizeService.Execute",
+		slog.String("details", "process started"))
+
+	err := l.validateOrchestratorRequest(orchestratorRequest)
 	if err != nil {
 		slog.ErrorContext(ctx, "summarizeService.Execute",
 			slog.String("details", "process error"),
 			slog.String("error", err.Error()))
-		return
+		return err
 	}
 
-	var request nosqlmodels.Request
-	err = l.orchestratorRepository.GetById(ctx, requestId, &request)
+	var request models.OrchestratorRequest
+	err = l.orchestratorRepository.GetById(ctx, *orchestratorRequest.RequestId, &request)
 	if err != nil {
 		slog.ErrorContext(ctx, "summarizeService.Execute",
 			slog.String("details", "process error"),
 			slog.String("error", err.Error()))
-		return
+		return err
+	}
+
+	summarize := stageEnabled(request.Options, enumactions.Summarize)
+	if summarize {
+		summarize, err = reserveSource(ctx, l.orchestratorRepository, request, *orchestratorRequest.ResearchId)
+		if err != nil {
+			slog.ErrorContext(ctx, "summarizeService.Execute",
+				slog.String("details", "process error"),
+				slog.String("error", err.Error()))
+			return err
+		}
+	}
+
+	if !summarize {
+		slog.InfoContext(ctx, "summarizeService.Execute",
+			slog.String("details", "stage disabled or sources exhausted, finishing research without a summary"))
+		err = l.finish(ctx, *orchestratorRequest.RequestId, *orchestratorRequest.ResearchId)
+		if err != nil {
+			slog.ErrorContext(ctx, "summarizeService.Execute",
+				slog.String("details", "process error"),
+				slog.String("error", err.Error()))
+			return err
+		}
+		return nil
 	}
 
-	err = l.validateOrchestratorData(request)
+	var research models.OrchestratorResearch
+	err = l.orchestratorRepository.GetById(ctx, *orchestratorRequest.ResearchId, &research)
 	if err != nil {
 		slog.ErrorContext(ctx, "summarizeService.Execute",
 			slog.String("details", "process error"),
 			slog.String("error", err.Error()))
-		return
+		return err
 	}
 
-	return fmt.Sprintf(
-		summarizeQuestionTemplate,
-		*request.Context,
-		*request.Research,
-		*research.Content,
-	), nil
-}
-
-func (l summarizeService) Execute(ctx context.Context, orchestratorRequest models.AiOrchestratorRequest) error {
-	slog.InfoContext(ctx, "summarizeService.Execute",
-		slog.String("details", "process started"))
-
-	err := l.validateOrchestratorRequest(orchestratorRequest)
+	err = l.validateResearch(research.Research)
 	if err != nil {
 		slog.ErrorContext(ctx, "summarizeService.Execute",
 			slog.String("details", "process error"),
@@ -131,7 +239,7 @@ func (l summarizeService) Execute(ctx context.Context, orchestratorRequest model
 		return err
 	}
 
-	question, err := l.buildQuestion(ctx, *orchestratorRequest.RequestId, *orchestratorRequest.ResearchId)
+	err = flagInjection(ctx, l.orchestratorRepository, research)
 	if err != nil {
 		slog.ErrorContext(ctx, "summarizeService.Execute",
 			slog.String("details", "process error"),
@@ -139,13 +247,7 @@ func (l summarizeService) Execute(ctx context.Context, orchestratorRequest model
 		return err
 	}
 
-	b, err := builder.BuildQueueGeminiMessage(
-		*orchestratorRequest.RequestId,
-		question,
-		properties.QueueNameAiOrchestratorCallback,
-		enumactions.Summarize,
-		0,
-	)
+	question, version, ready, err := l.buildQuestion(ctx, request, research)
 	if err != nil {
 		slog.ErrorContext(ctx, "summarizeService.Execute",
 			slog.String("details", "process error"),
@@ -153,7 +255,20 @@ func (l summarizeService) Execute(ctx context.Context, orchestratorRequest model
 		return err
 	}
 
-	err = l.queueGemini.Publish(ctx, b)
+	if !ready {
+		slog.InfoContext(ctx, "summarizeService.Execute",
+			slog.String("details", "waiting for the chunks of the content to be summarized"))
+		return nil
+	}
+
+	action := enumactions.Summarize
+	err = l.promptDispatcher.Dispatch(ctx, models.Prompt{
+		RequestId:       orchestratorRequest.RequestId,
+		ResearchId:      orchestratorRequest.ResearchId,
+		Action:          &action,
+		Question:        &question,
+		TemplateVersion: &version,
+	})
 	if err != nil {
 		slog.ErrorContext(ctx, "summarizeService.Execute",
 			slog.String("details", "process error"),
@@ -164,7 +279,7 @@ func (l summarizeService) Execute(ctx context.Context, orchestratorRequest model
 	return nil
 }
 
-func (l summarizeService) Callback(ctx context.Context, callback models.AiOrchestratorCallbackRequest) error {
+func (l summarizeService) Callback(ctx context.Context, callback models.AiOrchestratorCallbackRequest) (string, error) {
 	slog.InfoContext(ctx, "summarizeService.Callback",
 		slog.String("details", "process started"))
 
@@ -173,17 +288,53 @@ func (l summarizeService) Callback(ctx context.Context, callback models.AiOrches
 		slog.ErrorContext(ctx, "summarizeService.Execute",
 			slog.String("details", "process error"),
 			slog.String("error", err.Error()))
-		return err
+		return "", err
+	}
+
+	summary, errMessages := l.validateGeminiResponse(*callback.Response, attemptVersion(callback))
+	if errMessages != nil {
+		var request models.OrchestratorRequest
+		err = l.orchestratorRepository.GetById(ctx, *callback.RequestId, &request)
+		if err != nil {
+			slog.ErrorContext(ctx, "summarizeService.Callback",
+				slog.String("details", "process error"),
+				slog.String("error", err.Error()))
+			return "", err
+		}
+
+		var research models.OrchestratorResearch
+		err = l.orchestratorRepository.GetById(ctx, *callback.ResearchId, &research)
+		if err != nil {
+			slog.ErrorContext(ctx, "summarizeService.Callback",
+				slog.String("details", "process error"),
+				slog.String("error", err.Error()))
+			return "", err
+		}
+
+		var question, version string
+		question, version, err = l.retryQuestion(request, research, attemptVersion(callback))
+		if err != nil {
+			slog.ErrorContext(ctx, "summarizeService.Callback",
+				slog.String("details", "process error"),
+				slog.String("error", err.Error()))
+			return "", err
+		}
+
+		err = errortypes.NewInvalidAIResponseException(retryForward(callback, question, version), errMessages...)
+		slog.ErrorContext(ctx, "summarizeService.Callback",
+			slog.String("details", "process error"),
+			slog.String("error", err.Error()))
+		return "", err
 	}
 
 	err = l.orchestratorRepository.Update(ctx, *callback.ResearchId, map[string]any{
-		"summary": *callback.Response,
+		"summary": summary,
 	})
 	if err != nil {
 		slog.ErrorContext(ctx, "summarizeService.Execute",
 			slog.String("details", "process error"),
 			slog.String("error", err.Error()))
-		return err
+		return "", err
 	}
 
 	var b []byte
@@ -192,7 +343,7 @@ func (l summarizeService) Callback(ctx context.Context, callback models.AiOrches
 		slog.ErrorContext(ctx, "summarizeService.Callback",
 			slog.String("details", "process error"),
 			slog.String("error", err.Error()))
-		return err
+		return "", err
 	}
 
 	err = l.queueStatusManager.Publish(ctx, b)
@@ -200,17 +351,29 @@ func (l summarizeService) Callback(ctx context.Context, callback models.AiOrches
 		slog.ErrorContext(ctx, "summarizeService.Callback",
 			slog.String("details", "process error"),
 			slog.String("error", err.Error()))
-		return err
+		return "", err
+	}
+
+	err = l.researchTracker.Finish(ctx, *callback.RequestId, *callback.ResearchId)
+	if err != nil {
+		slog.ErrorContext(ctx, "summarizeService.Callback",
+			slog.String("details", "process error"),
+			slog.String("error", err.Error()))
+		return "", err
 	}
 
 	slog.InfoContext(ctx, "summarizeService.Callback",
 		slog.String("details", "process finished"))
-	return nil
+	return enumoutcomes.Done, nil
 }
-func NewSummarizeService(queueGemini, queueStatusManager interfaces.Queue, orchestratorRepository interfaces.OrchestratorRepository) interfaces.Service {
+func NewSummarizeService(promptDispatcher interfaces.PromptDispatcher, promptRegistry interfaces.PromptRegistry, responseParser interfaces.ResponseParser, queueStatusManager interfaces.Queue, orchestratorRepository interfaces.OrchestratorRepository, researchTracker interfaces.ResearchTracker, chunkSummarizer interfaces.ChunkSummarizer) interfaces.Service {
 	return &summarizeService{
 		queueStatusManager:     queueStatusManager,
-		queueGemini:            queueGemini,
+		promptDispatcher:       promptDispatcher,
+		promptRegistry:         promptRegistry,
+		responseParser:         responseParser,
 		orchestratorRepository: orchestratorRepository,
+		researchTracker:        researchTracker,
+		chunkSummarizer:        chunkSummarizer,
 	}
 }
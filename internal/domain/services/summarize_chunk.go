@@ -0,0 +1,124 @@
+package services
+
+import (
+	"context"
+	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/config/errortypes"
+	enumactions "github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/enums/actions"
+	enumoutcomes "github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/enums/outcomes"
+	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/interfaces"
+	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/models"
+	nosqlmodels "github.com/PesquisAi/pesquisai-database-lib/nosql/models"
+	"log/slog"
+)
+
+// summarizeChunkService handles the answers for the chunks of a content too
+// large for a single prompt. The chunk prompts are dispatched by the services
+// that need the content, through the chunk summarizer.
+type summarizeChunkService struct {
+	promptRegistry         interfaces.PromptRegistry
+	responseParser         interfaces.ResponseParser
+	orchestratorRepository interfaces.OrchestratorRepository
+	chunkSummarizer        interfaces.ChunkSummarizer
+}
+
+func (l summarizeChunkService) validaCallbackRequest(request models.AiOrchestratorCallbackRequest) error {
+	var messages []string
+	if request.ResearchId == nil {
+		messages = append(messages, `"research_id" is required to perform summarize chunk service`)
+	}
+	if request.Chunk == nil {
+		messages = append(messages, `"chunk" is required to perform summarize chunk service`)
+	}
+	if len(messages) > 0 {
+		return errortypes.NewValidationException(messages...)
+	}
+	return nil
+}
+
+func (l summarizeChunkService) validateGeminiResponse(response, version string) (string, []string) {
+	var answer summarizeAnswer
+	errorMessages := l.responseParser.Parse(enumactions.SummarizeChunk, response, &answer)
+	if errorMessages != nil {
+		return "", errorMessages
+	}
+	if echoesInstructions(l.promptRegistry, answer.Summary, version, enumactions.SummarizeChunk) {
+		return "", []string{echoMessage}
+	}
+	return answer.Summary, nil
+}
+
+func (l summarizeChunkService) buildQuestion(ctx context.Context, callback models.AiOrchestratorCallbackRequest) (question, version string, err error) {
+	var request nosqlmodels.Request
+	err = l.orchestratorRepository.GetById(ctx, *callback.RequestId, &request)
+	if err != nil {
+		return
+	}
+
+	var research models.OrchestratorResearch
+	err = l.orchestratorRepository.GetById(ctx, *callback.ResearchId, &research)
+	if err != nil {
+		return
+	}
+
+	return l.chunkSummarizer.Question(request, research, *callback.Chunk, attemptVersion(callback))
+}
+
+func (l summarizeChunkService) Execute(ctx context.Context, _ models.AiOrchestratorRequest) error {
+	err := errortypes.NewValidationException(`"summarize-chunk" is started by worth-summarize and summarize and can not be executed on its own`)
+	slog.ErrorContext(ctx, "summarizeChunkService.Execute",
+		slog.String("details", "process error"),
+		slog.String("error", err.Error()))
+	return err
+}
+
+func (l summarizeChunkService) Callback(ctx context.Context, callback models.AiOrchestratorCallbackRequest) (string, error) {
+	slog.InfoContext(ctx, "summarizeChunkService.Callback",
+		slog.String("details", "process started"))
+
+	err := l.validaCallbackRequest(callback)
+	if err != nil {
+		slog.ErrorContext(ctx, "summarizeChunkService.Callback",
+			slog.String("details", "process error"),
+			slog.String("error", err.Error()))
+		return "", err
+	}
+
+	summary, errMessages := l.validateGeminiResponse(*callback.Response, attemptVersion(callback))
+	if errMessages != nil {
+		var question, version string
+		question, version, err = l.buildQuestion(ctx, callback)
+		if err != nil {
+			slog.ErrorContext(ctx, "summarizeChunkService.Callback",
+				slog.String("details", "process error"),
+				slog.String("error", err.Error()))
+			return "", err
+		}
+
+		err = errortypes.NewInvalidAIResponseException(retryForward(callback, question, version), errMessages...)
+		slog.ErrorContext(ctx, "summarizeChunkService.Callback",
+			slog.String("details", "process error"),
+			slog.String("error", err.Error()))
+		return "", err
+	}
+
+	err = l.chunkSummarizer.Complete(ctx, *callback.RequestId, *callback.ResearchId, *callback.Chunk, summary)
+	if err != nil {
+		slog.ErrorContext(ctx, "summarizeChunkService.Callback",
+			slog.String("details", "process error"),
+			slog.String("error", err.Error()))
+		return "", err
+	}
+
+	slog.InfoContext(ctx, "summarizeChunkService.Callback",
+		slog.String("details", "process finished"))
+	return enumoutcomes.Done, nil
+}
+
+func NewSummarizeChunkService(promptRegistry interfaces.PromptRegistry, responseParser interfaces.ResponseParser, orchestratorRepository interfaces.OrchestratorRepository, chunkSummarizer interfaces.ChunkSummarizer) interfaces.Service {
+	return &summarizeChunkService{
+		promptRegistry:         promptRegistry,
+		responseParser:         responseParser,
+		orchestratorRepository: orchestratorRepository,
+		chunkSummarizer:        chunkSummarizer,
+	}
+}
@@ -2,42 +2,44 @@ package services
 
 import (
 	"context"
-	"fmt"
+	"errors"
 	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/config/errortypes"
-	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/config/properties"
 	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/builder"
 	enumactions "github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/enums/actions"
+	enumoutcomes "github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/enums/outcomes"
 	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/interfaces"
 	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/models"
 	nosqlmodels "github.com/PesquisAi/pesquisai-database-lib/nosql/models"
 	enumstatus "github.com/PesquisAi/pesquisai-database-lib/sql/enums/status"
 	"log/slog"
-	"strings"
 )
 
-const (
-	worthAccessQuestionTemplate = "You are part of a major project that performs researches for business and your only responsibility is to say" +
-		" Y for Yes and N for No if a web page is worth accessing given the context about the researcher, the research and the web page title and url.\n" +
-		"researcher context:%s.\n" +
-		"research:%s\n" +
-		"title:%s\n" +
-		"url:%s"
-)
+type worthAccessQuestion struct {
+	Context  string
+	Research string
+	Title    string
+	Link     string
+}
 
 type worthAccessingService struct {
 	queueStatusManager     interfaces.Queue
 	queueWebScraper        interfaces.Queue
-	queueGemini            interfaces.Queue
+	promptDispatcher       interfaces.PromptDispatcher
+	promptRegistry         interfaces.PromptRegistry
+	responseParser         interfaces.ResponseParser
 	orchestratorRepository interfaces.OrchestratorRepository
+	researchTracker        interfaces.ResearchTracker
+	ballot                 interfaces.Ballot
+	relevanceThreshold     int
 }
 
-func (l worthAccessingService) validateGeminiResponse(response string) (bool, *string) {
-	res := strings.ToLower(response)
-	if res != "n" && res != "y" {
-		message := fmt.Sprintf("worth acessing response with wrong format '%s'", response)
-		return false, &message
+func (l worthAccessingService) validateGeminiResponse(response string) (worthAnswer, []string) {
+	var answer worthAnswer
+	errorMessages := l.responseParser.Parse(enumactions.WorthAccessing, response, &answer)
+	if errorMessages != nil {
+		return worthAnswer{}, errorMessages
 	}
-	return res == "y", nil
+	return answer, nil
 }
 
 func (l worthAccessingService) validateOrchestratorData(request nosqlmodels.Request) error {
@@ -89,32 +91,46 @@ func (l worthAccessingService) validateResearch(request nosqlmodels.Research) er
 	return nil
 }
 
-func (l worthAccessingService) buildQuestion(ctx context.Context, requestId string, research nosqlmodels.Research) (question string, err error) {
-
-	var request nosqlmodels.Request
-	err = l.orchestratorRepository.GetById(ctx, requestId, &request)
+func (l worthAccessingService) buildQuestion(request nosqlmodels.Request, research nosqlmodels.Research, templateVersion string) (question, version string, err error) {
+	err = l.validateOrchestratorData(request)
 	if err != nil {
-		slog.ErrorContext(ctx, "worthAccessingService.Execute",
-			slog.String("details", "process error"),
-			slog.String("error", err.Error()))
 		return
 	}
 
-	err = l.validateOrchestratorData(request)
+	return l.promptRegistry.Render(enumactions.WorthAccessing, templateVersion, worthAccessQuestion{
+		Context:  *request.Context,
+		Research: *request.Research,
+		Title:    *research.Title,
+		Link:     *research.Link,
+	})
+}
+
+// access sends the research to the web scraper.
+func (l worthAccessingService) access(ctx context.Context, requestId, researchId string, research nosqlmodels.Research) error {
+	b, err := builder.BuildQueueWebScraperMessage(requestId, researchId, *research.Link)
 	if err != nil {
-		slog.ErrorContext(ctx, "worthAccessingService.Execute",
-			slog.String("details", "process error"),
-			slog.String("error", err.Error()))
-		return
+		return err
+	}
+
+	err = l.queueWebScraper.Publish(ctx, b)
+	if err != nil {
+		return err
+	}
+	return l.researchTracker.HandOff(ctx, researchId)
+}
+
+// finish ends a research that is not accessed.
+func (l worthAccessingService) finish(ctx context.Context, requestId, researchId string) error {
+	b, err := builder.BuildQueueStatusManagerMessage(nil, &researchId, enumstatus.FINISHED)
+	if err != nil {
+		return err
 	}
 
-	return fmt.Sprintf(
-		worthAccessQuestionTemplate,
-		*request.Context,
-		*request.Research,
-		*research.Title,
-		*research.Link,
-	), nil
+	err = l.queueStatusManager.Publish(ctx, b)
+	if err != nil {
+		return err
+	}
+	return l.researchTracker.Finish(ctx, requestId, researchId)
 }
 
 func (l worthAccessingService) Execute(ctx context.Context, orchestratorRequest models.AiOrchestratorRequest) error {
@@ -146,7 +162,8 @@ func (l worthAccessingService) Execute(ctx context.Context, orchestratorRequest
 		return err
 	}
 
-	question, err := l.buildQuestion(ctx, *orchestratorRequest.RequestId, research)
+	var request models.OrchestratorRequest
+	err = l.orchestratorRepository.GetById(ctx, *orchestratorRequest.RequestId, &request)
 	if err != nil {
 		slog.ErrorContext(ctx, "worthAccessingService.Execute",
 			slog.String("details", "process error"),
@@ -154,13 +171,20 @@ func (l worthAccessingService) Execute(ctx context.Context, orchestratorRequest
 		return err
 	}
 
-	b, err := builder.BuildQueueGeminiMessage(
-		*orchestratorRequest.RequestId,
-		question,
-		properties.QueueNameAiOrchestratorCallback,
-		enumactions.WorthAccessing,
-		0,
-	)
+	if !stageEnabled(request.Options, enumactions.WorthAccessing) {
+		slog.InfoContext(ctx, "worthAccessingService.Execute",
+			slog.String("details", "stage disabled, accessing research"))
+		err = l.access(ctx, *orchestratorRequest.RequestId, *orchestratorRequest.ResearchId, research)
+		if err != nil {
+			slog.ErrorContext(ctx, "worthAccessingService.Execute",
+				slog.String("details", "process error"),
+				slog.String("error", err.Error()))
+			return err
+		}
+		return nil
+	}
+
+	question, version, err := l.buildQuestion(request.Request, research, "")
 	if err != nil {
 		slog.ErrorContext(ctx, "worthAccessingService.Execute",
 			slog.String("details", "process error"),
@@ -168,7 +192,14 @@ func (l worthAccessingService) Execute(ctx context.Context, orchestratorRequest
 		return err
 	}
 
-	err = l.queueGemini.Publish(ctx, b)
+	action := enumactions.WorthAccessing
+	err = dispatchDecision(ctx, l.promptDispatcher, l.ballot, models.Prompt{
+		RequestId:       orchestratorRequest.RequestId,
+		ResearchId:      orchestratorRequest.ResearchId,
+		Action:          &action,
+		Question:        &question,
+		TemplateVersion: &version,
+	})
 	if err != nil {
 		slog.ErrorContext(ctx, "worthAccessingService.Execute",
 			slog.String("details", "process error"),
@@ -179,7 +210,7 @@ func (l worthAccessingService) Execute(ctx context.Context, orchestratorRequest
 	return nil
 }
 
-func (l worthAccessingService) Callback(ctx context.Context, callback models.AiOrchestratorCallbackRequest) error {
+func (l worthAccessingService) Callback(ctx context.Context, callback models.AiOrchestratorCallbackRequest) (string, error) {
 	slog.InfoContext(ctx, "worthAccessingService.Callback",
 		slog.String("details", "process started"))
 
@@ -188,7 +219,7 @@ func (l worthAccessingService) Callback(ctx context.Context, callback models.AiO
 		slog.ErrorContext(ctx, "worthAccessingService.Execute",
 			slog.String("details", "process error"),
 			slog.String("error", err.Error()))
-		return err
+		return "", err
 	}
 
 	var research nosqlmodels.Research
@@ -197,7 +228,7 @@ func (l worthAccessingService) Callback(ctx context.Context, callback models.AiO
 		slog.ErrorContext(ctx, "worthAccessingService.Execute",
 			slog.String("details", "process error"),
 			slog.String("error", err.Error()))
-		return err
+		return "", err
 	}
 
 	err = l.validateResearch(research)
@@ -205,80 +236,110 @@ func (l worthAccessingService) Callback(ctx context.Context, callback models.AiO
 		slog.ErrorContext(ctx, "worthAccessingService.Execute",
 			slog.String("details", "process error"),
 			slog.String("error", err.Error()))
-		return err
+		return "", err
 	}
 
-	worth, errMessage := l.validateGeminiResponse(*callback.Response)
-	if errMessage != nil {
-		var request nosqlmodels.Request
-		err = l.orchestratorRepository.GetById(ctx, *callback.RequestId, &request)
-		if err != nil {
+	// An abstention has no answer, only the votes of the other voters.
+	var answer worthAnswer
+	if !callback.Abstained {
+		var errMessages []string
+		answer, errMessages = l.validateGeminiResponse(*callback.Response)
+		if errMessages != nil {
+			var request nosqlmodels.Request
+			err = l.orchestratorRepository.GetById(ctx, *callback.RequestId, &request)
+			if err != nil {
+				slog.ErrorContext(ctx, "worthAccessingService.Callback",
+					slog.String("details", "process error"),
+					slog.String("error", err.Error()))
+				return "", err
+			}
+
+			var question, version string
+			question, version, err = l.buildQuestion(request, research, attemptVersion(callback))
+			if err != nil {
+				slog.ErrorContext(ctx, "worthAccessingService.Execute",
+					slog.String("details", "process error"),
+					slog.String("error", err.Error()))
+				return "", err
+			}
+
+			err = errortypes.NewInvalidAIResponseException(retryForward(callback, question, version), errMessages...)
 			slog.ErrorContext(ctx, "worthAccessingService.Callback",
 				slog.String("details", "process error"),
 				slog.String("error", err.Error()))
-			return err
+			return "", err
 		}
+	}
 
-		var question string
-		question, err = l.buildQuestion(ctx, *callback.RequestId, research)
+	decided, worth, err := judge(ctx, l.orchestratorRepository, l.ballot, l.relevanceThreshold, callback, answer)
+	if errors.Is(err, errUndecidable) {
+		slog.WarnContext(ctx, "worthAccessingService.Callback",
+			slog.String("details", "every voter abstained, failing research"))
+		err = l.researchTracker.Fail(ctx, *callback.RequestId, *callback.ResearchId)
 		if err != nil {
-			slog.ErrorContext(ctx, "worthAccessingService.Execute",
+			slog.ErrorContext(ctx, "worthAccessingService.Callback",
 				slog.String("details", "process error"),
 				slog.String("error", err.Error()))
-			return err
+			return "", err
 		}
-
-		err = errortypes.NewInvalidAIResponseException(*callback.RequestId, question, enumactions.WorthAccessing, callback.ReceiveCount+1, *errMessage)
+		return enumoutcomes.Failed, nil
+	}
+	if err != nil {
 		slog.ErrorContext(ctx, "worthAccessingService.Callback",
 			slog.String("details", "process error"),
 			slog.String("error", err.Error()))
-		return err
+		return "", err
 	}
 
-	var b []byte
-	if worth {
-		b, err = builder.BuildQueueWebScraperMessage(*callback.RequestId, *callback.ResearchId, *research.Link)
-		if err != nil {
-			slog.ErrorContext(ctx, "worthAccessingService.Callback",
-				slog.String("details", "process error"),
-				slog.String("error", err.Error()))
-			return err
-		}
+	if !decided {
+		slog.InfoContext(ctx, "worthAccessingService.Callback",
+			slog.String("details", "vote recorded, waiting for the other voters"))
+		return enumoutcomes.Voted, nil
+	}
 
-		err = l.queueWebScraper.Publish(ctx, b)
+	if worth {
+		err = l.access(ctx, *callback.RequestId, *callback.ResearchId, research)
 		if err != nil {
 			slog.ErrorContext(ctx, "worthAccessingService.Callback",
 				slog.String("details", "process error"),
 				slog.String("error", err.Error()))
-			return err
+			return "", err
 		}
 	} else {
-		b, err = builder.BuildQueueStatusManagerMessage(nil, callback.ResearchId, enumstatus.FINISHED)
+		err = releaseSource(ctx, l.orchestratorRepository, *callback.RequestId, *callback.ResearchId)
 		if err != nil {
 			slog.ErrorContext(ctx, "worthAccessingService.Callback",
 				slog.String("details", "process error"),
 				slog.String("error", err.Error()))
-			return err
+			return "", err
 		}
 
-		err = l.queueStatusManager.Publish(ctx, b)
+		err = l.finish(ctx, *callback.RequestId, *callback.ResearchId)
 		if err != nil {
 			slog.ErrorContext(ctx, "worthAccessingService.Callback",
 				slog.String("details", "process error"),
 				slog.String("error", err.Error()))
-			return err
+			return "", err
 		}
 	}
 
 	slog.InfoContext(ctx, "worthAccessingService.Callback",
 		slog.String("details", "process finished"))
-	return nil
+	if !worth {
+		return enumoutcomes.Rejected, nil
+	}
+	return enumoutcomes.Accepted, nil
 }
-func NewWorthAccessingService(queueGemini, queueWebScraper, queueStatusManager interfaces.Queue, orchestratorRepository interfaces.OrchestratorRepository) interfaces.Service {
+func NewWorthAccessingService(promptDispatcher interfaces.PromptDispatcher, promptRegistry interfaces.PromptRegistry, responseParser interfaces.ResponseParser, queueWebScraper, queueStatusManager interfaces.Queue, orchestratorRepository interfaces.OrchestratorRepository, researchTracker interfaces.ResearchTracker, ballot interfaces.Ballot, relevanceThreshold int) interfaces.Service {
 	return &worthAccessingService{
 		queueStatusManager:     queueStatusManager,
 		queueWebScraper:        queueWebScraper,
-		queueGemini:            queueGemini,
+		promptDispatcher:       promptDispatcher,
+		promptRegistry:         promptRegistry,
+		responseParser:         responseParser,
 		orchestratorRepository: orchestratorRepository,
+		researchTracker:        researchTracker,
+		ballot:                 ballot,
+		relevanceThreshold:     relevanceThreshold,
 	}
 }
@@ -2,46 +2,46 @@ package services
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/config/errortypes"
-	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/config/properties"
 	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/delivery/dtos"
 	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/builder"
+	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/chunking"
 	enumactions "github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/enums/actions"
+	enumoutcomes "github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/enums/outcomes"
 	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/interfaces"
 	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/models"
+	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/pipeline"
 	nosqlmodels "github.com/PesquisAi/pesquisai-database-lib/nosql/models"
 	enumstatus "github.com/PesquisAi/pesquisai-database-lib/sql/enums/status"
 	"log/slog"
-	"strings"
-)
-
-const (
-	worthSummarizeQuestionTemplate = "You are a part of a major project that performs researches for business and you have one responsibility. " +
-		"To determine if the content in the webpage is essential for the researcher." +
-		" It really needs to be essential for you to consider it." +
-		" If its not, you should answer me with the letter N and nothing else. " +
-		"If the content is really important given the research, answer me with a Y and nothing else." +
-		" You will receive a context about the researcher and the research.\n" +
-		"researcher context:%s\n" +
-		"research:%s\n" +
-		"Web page content:%s"
 )
 
 type worthSummarizeService struct {
 	queueStatusManager     interfaces.Queue
 	queueOrchestrator      interfaces.Queue
-	queueGemini            interfaces.Queue
+	promptDispatcher       interfaces.PromptDispatcher
+	promptRegistry         interfaces.PromptRegistry
+	responseParser         interfaces.ResponseParser
 	orchestratorRepository interfaces.OrchestratorRepository
+	researchTracker        interfaces.ResearchTracker
+	chunkSummarizer        interfaces.ChunkSummarizer
+	ballot                 interfaces.Ballot
+	relevanceThreshold     int
+	pipeline               pipeline.Pipeline
 }
 
-func (l worthSummarizeService) validateGeminiResponse(response string) (bool, *string) {
-	res := strings.ToLower(response)
-	if res != "n" && res != "y" {
-		message := fmt.Sprintf("worth acessing response with wrong format '%s'", response)
-		return false, &message
+func (l worthSummarizeService) validateGeminiResponse(response, version string) (worthAnswer, []string) {
+	var answer worthAnswer
+	errorMessages := l.responseParser.Parse(enumactions.WorthSummarize, response, &answer)
+	if errorMessages != nil {
+		return worthAnswer{}, errorMessages
+	}
+	if echoesInstructions(l.promptRegistry, answer.Reason, version, enumactions.WorthSummarize) {
+		return worthAnswer{}, []string{echoMessage}
 	}
-	return res == "y", nil
+	return answer, nil
 }
 
 func (l worthSummarizeService) validateOrchestratorData(request nosqlmodels.Request) error {
@@ -93,31 +93,71 @@ func (l worthSummarizeService) validateResearch(research nosqlmodels.Research) e
 	return nil
 }
 
-func (l worthSummarizeService) buildQuestion(ctx context.Context, requestId string, research nosqlmodels.Research) (question string, err error) {
-
-	var request nosqlmodels.Request
-	err = l.orchestratorRepository.GetById(ctx, requestId, &request)
+// render renders the question of the research from its content, or from the
+// summaries of its chunks when it was summarized by chunks. It has no side
+// effect, so the retry of an attempt renders it again.
+func (l worthSummarizeService) render(request nosqlmodels.Request, research models.OrchestratorResearch, summaries []string, templateVersion string) (question, version string, err error) {
+	err = l.validateOrchestratorData(request)
 	if err != nil {
-		slog.ErrorContext(ctx, "worthSummarizeService.Execute",
-			slog.String("details", "process error"),
-			slog.String("error", err.Error()))
 		return
 	}
 
+	content := *research.Content
+	if summaries != nil {
+		content = chunking.Digest(summaries)
+	}
+
+	return l.promptRegistry.Render(enumactions.WorthSummarize, templateVersion, contentQuestion{
+		Context:  *request.Context,
+		Research: *request.Research,
+		Content:  content,
+	})
+}
+
+// buildQuestion prepares the content of the research, whose chunks are
+// summarized first when it does not fit a prompt, and renders its question
+// once ready.
+func (l worthSummarizeService) buildQuestion(ctx context.Context, request nosqlmodels.Request, research models.OrchestratorResearch) (question, version string, ready bool, err error) {
 	err = l.validateOrchestratorData(request)
 	if err != nil {
-		slog.ErrorContext(ctx, "worthSummarizeService.Execute",
-			slog.String("details", "process error"),
-			slog.String("error", err.Error()))
 		return
 	}
 
-	return fmt.Sprintf(
-		worthSummarizeQuestionTemplate,
-		*request.Context,
-		*request.Research,
-		*research.Content,
-	), nil
+	summaries, ready, err := l.chunkSummarizer.Prepare(ctx, request, research, enumactions.WorthSummarize)
+	if err != nil || !ready {
+		return
+	}
+
+	question, version, err = l.render(request, research, summaries, "")
+	return
+}
+
+// retryQuestion renders the question of an attempt again, from the content
+// already prepared for it.
+func (l worthSummarizeService) retryQuestion(request nosqlmodels.Request, research models.OrchestratorResearch, templateVersion string) (string, string, error) {
+	summaries, ready := l.chunkSummarizer.Summaries(research)
+	if !ready {
+		return "", "", fmt.Errorf("content of research %s is not prepared, its question can not be rendered again", *research.ID)
+	}
+	return l.render(request, research, summaries, templateVersion)
+}
+
+// skip moves the research on as if it had been judged worth summarizing.
+func (l worthSummarizeService) skip(ctx context.Context, orchestratorRequest models.AiOrchestratorRequest) error {
+	next, ok := l.pipeline.Next(enumactions.WorthSummarize, enumoutcomes.Accepted)
+	if !ok {
+		return nil
+	}
+
+	b, err := builder.BuildQueueOrchestratorMessage(dtos.AiOrchestratorRequest{
+		RequestId:  orchestratorRequest.RequestId,
+		ResearchId: orchestratorRequest.ResearchId,
+		Action:     &next,
+	})
+	if err != nil {
+		return err
+	}
+	return l.queueOrchestrator.Publish(ctx, b)
 }
 
 func (l worthSummarizeService) Execute(ctx context.Context, orchestratorRequest models.AiOrchestratorRequest) error {
@@ -132,7 +172,7 @@ func (l worthSummarizeService) Execute(ctx context.Context, orchestratorRequest
 		return err
 	}
 
-	var research nosqlmodels.Research
+	var research models.OrchestratorResearch
 	err = l.orchestratorRepository.GetById(ctx, *orchestratorRequest.ResearchId, &research)
 	if err != nil {
 		slog.ErrorContext(ctx, "worthSummarizeService.Execute",
@@ -141,7 +181,30 @@ func (l worthSummarizeService) Execute(ctx context.Context, orchestratorRequest
 		return err
 	}
 
-	err = l.validateResearch(research)
+	err = l.validateResearch(research.Research)
+	if err != nil {
+		slog.ErrorContext(ctx, "worthSummarizeService.Execute",
+			slog.String("details", "process error"),
+			slog.String("error", err.Error()))
+		return err
+	}
+
+	resumed, err := l.researchTracker.Resume(ctx, research)
+	if err != nil {
+		slog.ErrorContext(ctx, "worthSummarizeService.Execute",
+			slog.String("details", "process error"),
+			slog.String("error", err.Error()))
+		return err
+	}
+
+	if !resumed {
+		slog.WarnContext(ctx, "worthSummarizeService.Execute",
+			slog.String("details", "research already finished as failed, dropping its content"))
+		return nil
+	}
+
+	var request models.OrchestratorRequest
+	err = l.orchestratorRepository.GetById(ctx, *orchestratorRequest.RequestId, &request)
 	if err != nil {
 		slog.ErrorContext(ctx, "worthSummarizeService.Execute",
 			slog.String("details", "process error"),
@@ -149,7 +212,20 @@ func (l worthSummarizeService) Execute(ctx context.Context, orchestratorRequest
 		return err
 	}
 
-	question, err := l.buildQuestion(ctx, *orchestratorRequest.RequestId, research)
+	if !stageEnabled(request.Options, enumactions.WorthSummarize) {
+		slog.InfoContext(ctx, "worthSummarizeService.Execute",
+			slog.String("details", "stage disabled, moving research on"))
+		err = l.skip(ctx, orchestratorRequest)
+		if err != nil {
+			slog.ErrorContext(ctx, "worthSummarizeService.Execute",
+				slog.String("details", "process error"),
+				slog.String("error", err.Error()))
+			return err
+		}
+		return nil
+	}
+
+	err = flagInjection(ctx, l.orchestratorRepository, research)
 	if err != nil {
 		slog.ErrorContext(ctx, "worthSummarizeService.Execute",
 			slog.String("details", "process error"),
@@ -157,13 +233,7 @@ func (l worthSummarizeService) Execute(ctx context.Context, orchestratorRequest
 		return err
 	}
 
-	b, err := builder.BuildQueueGeminiMessage(
-		*orchestratorRequest.RequestId,
-		question,
-		properties.QueueNameAiOrchestratorCallback,
-		enumactions.WorthSummarize,
-		0,
-	)
+	question, version, ready, err := l.buildQuestion(ctx, request.Request, research)
 	if err != nil {
 		slog.ErrorContext(ctx, "worthSummarizeService.Execute",
 			slog.String("details", "process error"),
@@ -171,7 +241,20 @@ func (l worthSummarizeService) Execute(ctx context.Context, orchestratorRequest
 		return err
 	}
 
-	err = l.queueGemini.Publish(ctx, b)
+	if !ready {
+		slog.InfoContext(ctx, "worthSummarizeService.Execute",
+			slog.String("details", "waiting for the chunks of the content to be summarized"))
+		return nil
+	}
+
+	action := enumactions.WorthSummarize
+	err = dispatchDecision(ctx, l.promptDispatcher, l.ballot, models.Prompt{
+		RequestId:       orchestratorRequest.RequestId,
+		ResearchId:      orchestratorRequest.ResearchId,
+		Action:          &action,
+		Question:        &question,
+		TemplateVersion: &version,
+	})
 	if err != nil {
 		slog.ErrorContext(ctx, "worthSummarizeService.Execute",
 			slog.String("details", "process error"),
@@ -182,7 +265,7 @@ func (l worthSummarizeService) Execute(ctx context.Context, orchestratorRequest
 	return nil
 }
 
-func (l worthSummarizeService) Callback(ctx context.Context, callback models.AiOrchestratorCallbackRequest) error {
+func (l worthSummarizeService) Callback(ctx context.Context, callback models.AiOrchestratorCallbackRequest) (string, error) {
 	slog.InfoContext(ctx, "worthSummarizeService.Callback",
 		slog.String("details", "process started"))
 
@@ -191,102 +274,139 @@ func (l worthSummarizeService) Callback(ctx context.Context, callback models.AiO
 		slog.ErrorContext(ctx, "worthSummarizeService.Execute",
 			slog.String("details", "process error"),
 			slog.String("error", err.Error()))
-		return err
+		return "", err
 	}
 
-	var research nosqlmodels.Research
+	var research models.OrchestratorResearch
 	err = l.orchestratorRepository.GetById(ctx, *callback.ResearchId, &research)
 	if err != nil {
 		slog.ErrorContext(ctx, "worthSummarizeService.Execute",
 			slog.String("details", "process error"),
 			slog.String("error", err.Error()))
-		return err
+		return "", err
 	}
 
-	err = l.validateResearch(research)
+	err = l.validateResearch(research.Research)
 	if err != nil {
 		slog.ErrorContext(ctx, "worthSummarizeService.Execute",
 			slog.String("details", "process error"),
 			slog.String("error", err.Error()))
-		return err
+		return "", err
 	}
 
-	worth, errMessage := l.validateGeminiResponse(*callback.Response)
-	if errMessage != nil {
-		var request nosqlmodels.Request
-		err = l.orchestratorRepository.GetById(ctx, *callback.RequestId, &request)
-		if err != nil {
+	// An abstention has no answer, only the votes of the other voters.
+	var answer worthAnswer
+	if !callback.Abstained {
+		var errMessages []string
+		answer, errMessages = l.validateGeminiResponse(*callback.Response, attemptVersion(callback))
+		if errMessages != nil {
+			var request nosqlmodels.Request
+			err = l.orchestratorRepository.GetById(ctx, *callback.RequestId, &request)
+			if err != nil {
+				slog.ErrorContext(ctx, "worthSummarizeService.Callback",
+					slog.String("details", "process error"),
+					slog.String("error", err.Error()))
+				return "", err
+			}
+
+			var question, version string
+			question, version, err = l.retryQuestion(request, research, attemptVersion(callback))
+			if err != nil {
+				slog.ErrorContext(ctx, "worthSummarizeService.Execute",
+					slog.String("details", "process error"),
+					slog.String("error", err.Error()))
+				return "", err
+			}
+
+			err = errortypes.NewInvalidAIResponseException(retryForward(callback, question, version), errMessages...)
 			slog.ErrorContext(ctx, "worthSummarizeService.Callback",
 				slog.String("details", "process error"),
 				slog.String("error", err.Error()))
-			return err
+			return "", err
 		}
+	}
 
-		var question string
-		question, err = l.buildQuestion(ctx, *callback.RequestId, research)
+	decided, worth, err := judge(ctx, l.orchestratorRepository, l.ballot, l.relevanceThreshold, callback, answer)
+	if errors.Is(err, errUndecidable) {
+		slog.WarnContext(ctx, "worthSummarizeService.Callback",
+			slog.String("details", "every voter abstained, failing research"))
+		err = l.researchTracker.Fail(ctx, *callback.RequestId, *callback.ResearchId)
 		if err != nil {
-			slog.ErrorContext(ctx, "worthSummarizeService.Execute",
+			slog.ErrorContext(ctx, "worthSummarizeService.Callback",
 				slog.String("details", "process error"),
 				slog.String("error", err.Error()))
-			return err
+			return "", err
 		}
-
-		err = errortypes.NewInvalidAIResponseException(*callback.RequestId, question, enumactions.WorthSummarize, callback.ReceiveCount+1, *errMessage)
+		return enumoutcomes.Failed, nil
+	}
+	if err != nil {
 		slog.ErrorContext(ctx, "worthSummarizeService.Callback",
 			slog.String("details", "process error"),
 			slog.String("error", err.Error()))
-		return err
+		return "", err
 	}
 
-	var b []byte
-	if worth {
-		action := enumactions.Summarize
-		b, err = builder.BuildQueueOrchestratorMessage(dtos.AiOrchestratorRequest{
-			RequestId:  callback.RequestId,
-			ResearchId: callback.ResearchId,
-			Action:     &action,
-		})
+	if !decided {
+		slog.InfoContext(ctx, "worthSummarizeService.Callback",
+			slog.String("details", "vote recorded, waiting for the other voters"))
+		return enumoutcomes.Voted, nil
+	}
+
+	if !worth {
+		err = releaseSource(ctx, l.orchestratorRepository, *callback.RequestId, *callback.ResearchId)
 		if err != nil {
 			slog.ErrorContext(ctx, "worthSummarizeService.Callback",
 				slog.String("details", "process error"),
 				slog.String("error", err.Error()))
-			return err
+			return "", err
 		}
 
-		err = l.queueOrchestrator.Publish(ctx, b)
+		var b []byte
+		b, err = builder.BuildQueueStatusManagerMessage(nil, callback.ResearchId, enumstatus.FINISHED)
 		if err != nil {
 			slog.ErrorContext(ctx, "worthSummarizeService.Callback",
 				slog.String("details", "process error"),
 				slog.String("error", err.Error()))
-			return err
+			return "", err
 		}
-	} else {
-		b, err = builder.BuildQueueStatusManagerMessage(nil, callback.ResearchId, enumstatus.FINISHED)
+
+		err = l.queueStatusManager.Publish(ctx, b)
 		if err != nil {
 			slog.ErrorContext(ctx, "worthSummarizeService.Callback",
 				slog.String("details", "process error"),
 				slog.String("error", err.Error()))
-			return err
+			return "", err
 		}
 
-		err = l.queueStatusManager.Publish(ctx, b)
+		err = l.researchTracker.Finish(ctx, *callback.RequestId, *callback.ResearchId)
 		if err != nil {
 			slog.ErrorContext(ctx, "worthSummarizeService.Callback",
 				slog.String("details", "process error"),
 				slog.String("error", err.Error()))
-			return err
+			return "", err
 		}
+
+		slog.InfoContext(ctx, "worthSummarizeService.Callback",
+			slog.String("details", "process finished"))
+		return enumoutcomes.Rejected, nil
 	}
 
 	slog.InfoContext(ctx, "worthSummarizeService.Callback",
 		slog.String("details", "process finished"))
-	return nil
+	return enumoutcomes.Accepted, nil
 }
-func NewWorthSummarizeService(queueGemini, queueOrchestrator, queueStatusManager interfaces.Queue, orchestratorRepository interfaces.OrchestratorRepository) interfaces.Service {
+func NewWorthSummarizeService(promptDispatcher interfaces.PromptDispatcher, promptRegistry interfaces.PromptRegistry, responseParser interfaces.ResponseParser, queueStatusManager, queueOrchestrator interfaces.Queue, orchestratorRepository interfaces.OrchestratorRepository, researchTracker interfaces.ResearchTracker, chunkSummarizer interfaces.ChunkSummarizer, ballot interfaces.Ballot, relevanceThreshold int, pipeline pipeline.Pipeline) interfaces.Service {
 	return &worthSummarizeService{
 		queueStatusManager:     queueStatusManager,
 		queueOrchestrator:      queueOrchestrator,
-		queueGemini:            queueGemini,
+		promptDispatcher:       promptDispatcher,
+		promptRegistry:         promptRegistry,
+		responseParser:         responseParser,
 		orchestratorRepository: orchestratorRepository,
+		researchTracker:        researchTracker,
+		chunkSummarizer:        chunkSummarizer,
+		ballot:                 ballot,
+		relevanceThreshold:     relevanceThreshold,
+		pipeline:               pipeline,
 	}
 }
@@ -0,0 +1,196 @@
+package sweeper
+
+import (
+	"context"
+	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/config/properties"
+	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/builder"
+	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/interfaces"
+	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/models"
+	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/pipeline"
+	enumstatus "github.com/PesquisAi/pesquisai-database-lib/sql/enums/status"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"log/slog"
+	"time"
+)
+
+const batchSize = 100
+
+// sweeper periodically looks for prompts whose deadline passed without an LLM
+// answer. They are published again while they are under MAX_AI_RECEIVE_COUNT,
+// otherwise the research (or request) is marked as failed, or the voter of an
+// ensemble abstains. It also fails the researches the web-scraper did not send
+// back in time and completes the requests whose total of researches came late.
+type sweeper struct {
+	useCase            interfaces.UseCase
+	promptRepository   interfaces.PromptRepository
+	promptDispatcher   interfaces.PromptDispatcher
+	queueStatusManager interfaces.Queue
+	researchTracker    interfaces.ResearchTracker
+	cancellationGuard  interfaces.CancellationGuard
+	pipeline           pipeline.Pipeline
+	interval           time.Duration
+}
+
+func (s sweeper) fail(ctx context.Context, prompt models.Prompt) error {
+	if prompt.ResearchId != nil {
+		err := s.researchTracker.Fail(ctx, *prompt.RequestId, *prompt.ResearchId)
+		if err != nil {
+			return err
+		}
+	} else {
+		b, err := builder.BuildQueueStatusManagerMessage(prompt.RequestId, nil, enumstatus.ERROR)
+		if err != nil {
+			return err
+		}
+
+		err = s.queueStatusManager.Publish(ctx, b)
+		if err != nil {
+			return err
+		}
+	}
+
+	return s.promptDispatcher.Resolve(ctx, *prompt.RequestId, prompt.ResearchId, *prompt.Action, prompt.Chunk, prompt.Voter)
+}
+
+// abstain resolves the prompt of a voter as its abstention. It is processed as
+// the callback of the prompt, so the ballot is decided by the other voters and
+// is left alone once decided; the research fails only when no voter answered.
+func (s sweeper) abstain(ctx context.Context, prompt models.Prompt) error {
+	return s.useCase.OrchestrateCallback(ctx, models.AiOrchestratorCallbackRequest{
+		RequestId:       prompt.RequestId,
+		ResearchId:      prompt.ResearchId,
+		Action:          prompt.Action,
+		Chunk:           prompt.Chunk,
+		Voter:           prompt.Voter,
+		ReceiveCount:    prompt.ReceiveCount,
+		DispatchId:      prompt.DispatchId,
+		TemplateVersion: prompt.TemplateVersion,
+		Abstained:       true,
+	})
+}
+
+// claim takes the timed out prompt for this sweep. Only the dispatch it was
+// found with is claimed, and the claim replaces the dispatch id at once, so
+// an answer of that dispatch arriving from now on is a late one.
+func (s sweeper) claim(ctx context.Context, prompt *models.Prompt, now time.Time) (bool, error) {
+	filter := bson.M{"_id": *prompt.ID, "deadline": *prompt.Deadline}
+	if prompt.DispatchId != nil {
+		filter["dispatchId"] = *prompt.DispatchId
+	}
+
+	dispatchId := primitive.NewObjectID().Hex()
+	claimed, err := s.promptRepository.UpdateOne(ctx, filter,
+		bson.M{"$set": bson.M{"deadline": now.Add(s.pipeline.Deadline(*prompt.Action)), "dispatchId": dispatchId}},
+	)
+	if err != nil || !claimed {
+		return false, err
+	}
+
+	prompt.DispatchId = &dispatchId
+	return true, nil
+}
+
+func (s sweeper) sweepPrompt(ctx context.Context, prompt models.Prompt, now time.Time) error {
+	claimed, err := s.claim(ctx, &prompt, now)
+	if err != nil || !claimed {
+		return err
+	}
+
+	cancelled, err := s.cancellationGuard.IsCancelled(ctx, *prompt.RequestId)
+	if err != nil {
+		return err
+	}
+
+	if cancelled {
+		slog.InfoContext(ctx, "sweeper.sweepPrompt",
+			slog.String("details", "request cancelled, dropping prompt"),
+			slog.String("prompt", *prompt.ID))
+		return s.promptDispatcher.Resolve(ctx, *prompt.RequestId, prompt.ResearchId, *prompt.Action, prompt.Chunk, prompt.Voter)
+	}
+
+	receiveCount := prompt.ReceiveCount + 1
+	if receiveCount >= properties.GetMaxAiReceiveCount() && prompt.Voter != nil {
+		slog.WarnContext(ctx, "sweeper.sweepPrompt",
+			slog.String("details", "voter timed out too many times, abstaining"),
+			slog.String("prompt", *prompt.ID))
+		return s.abstain(ctx, prompt)
+	}
+
+	if receiveCount >= properties.GetMaxAiReceiveCount() {
+		slog.WarnContext(ctx, "sweeper.sweepPrompt",
+			slog.String("details", "prompt timed out too many times, failing it"),
+			slog.String("prompt", *prompt.ID))
+		return s.fail(ctx, prompt)
+	}
+
+	slog.WarnContext(ctx, "sweeper.sweepPrompt",
+		slog.String("details", "prompt timed out, publishing it again"),
+		slog.String("prompt", *prompt.ID),
+		slog.Int("receiveCount", receiveCount))
+
+	prompt.ReceiveCount = receiveCount
+	return s.promptDispatcher.Dispatch(ctx, prompt)
+}
+
+func (s sweeper) sweep(ctx context.Context) error {
+	now := time.Now().UTC()
+
+	var prompts []models.Prompt
+	err := s.promptRepository.Find(ctx, bson.M{"deadline": bson.M{"$lt": now}}, batchSize, &prompts)
+	if err != nil {
+		return err
+	}
+
+	for _, prompt := range prompts {
+		err = s.sweepPrompt(ctx, prompt, now)
+		if err != nil {
+			slog.ErrorContext(ctx, "sweeper.sweep",
+				slog.String("details", "process error"),
+				slog.String("prompt", *prompt.ID),
+				slog.String("error", err.Error()))
+		}
+	}
+
+	err = s.researchTracker.Expire(ctx)
+	if err != nil {
+		return err
+	}
+	return s.researchTracker.Settle(ctx)
+}
+
+func (s sweeper) Run(ctx context.Context) error {
+	slog.InfoContext(ctx, "sweeper.Run",
+		slog.String("details", "process started"),
+		slog.Duration("interval", s.interval))
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			err := s.sweep(ctx)
+			if err != nil {
+				slog.ErrorContext(ctx, "sweeper.Run",
+					slog.String("details", "process error"),
+					slog.String("error", err.Error()))
+			}
+		}
+	}
+}
+
+func NewSweeper(useCase interfaces.UseCase, promptRepository interfaces.PromptRepository, promptDispatcher interfaces.PromptDispatcher, queueStatusManager interfaces.Queue, researchTracker interfaces.ResearchTracker, cancellationGuard interfaces.CancellationGuard, pipeline pipeline.Pipeline, interval time.Duration) interfaces.Sweeper {
+	return &sweeper{
+		useCase:            useCase,
+		promptRepository:   promptRepository,
+		promptDispatcher:   promptDispatcher,
+		queueStatusManager: queueStatusManager,
+		researchTracker:    researchTracker,
+		cancellationGuard:  cancellationGuard,
+		pipeline:           pipeline,
+		interval:           interval,
+	}
+}
@@ -0,0 +1,206 @@
+package sweeper
+
+import (
+	"context"
+	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/dispatcher"
+	enumactions "github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/enums/actions"
+	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/models"
+	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/pipeline"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// promptRepository keeps the prompts in memory, matching filters by equality
+// of their fields.
+type promptRepository struct {
+	documents map[string]bson.M
+}
+
+func normalize(v any) bson.M {
+	b, err := bson.Marshal(v)
+	if err != nil {
+		panic(err)
+	}
+	var m bson.M
+	if err = bson.Unmarshal(b, &m); err != nil {
+		panic(err)
+	}
+	return m
+}
+
+func (r *promptRepository) GetById(_ context.Context, id string, model interface{}) error {
+	document, ok := r.documents[id]
+	if !ok {
+		return mongo.ErrNoDocuments
+	}
+	b, err := bson.Marshal(document)
+	if err != nil {
+		return err
+	}
+	return bson.Unmarshal(b, model)
+}
+
+func (r *promptRepository) Upsert(_ context.Context, id string, values bson.M) error {
+	document, ok := r.documents[id]
+	if !ok {
+		document = bson.M{"_id": id}
+		r.documents[id] = document
+	}
+	for key, value := range normalize(values) {
+		document[key] = value
+	}
+	return nil
+}
+
+func (r *promptRepository) UpdateOne(_ context.Context, filter bson.M, update bson.M) (bool, error) {
+	filter = normalize(filter)
+	for _, document := range r.documents {
+		matches := true
+		for key, value := range filter {
+			if !reflect.DeepEqual(document[key], value) {
+				matches = false
+				break
+			}
+		}
+		if !matches {
+			continue
+		}
+
+		for key, value := range normalize(update["$set"]) {
+			document[key] = value
+		}
+		return true, nil
+	}
+	return false, nil
+}
+
+func (r *promptRepository) Find(context.Context, bson.M, int64, interface{}) error {
+	return nil
+}
+
+func (r *promptRepository) Delete(_ context.Context, id string) error {
+	delete(r.documents, id)
+	return nil
+}
+
+func (r *promptRepository) Connect(string, string) {}
+
+type llmClient struct {
+	sent []models.Prompt
+}
+
+func (c *llmClient) Send(_ context.Context, prompt models.Prompt) error {
+	c.sent = append(c.sent, prompt)
+	return nil
+}
+
+type usageRecorder struct{}
+
+func (usageRecorder) Prompt(context.Context, models.Prompt) error { return nil }
+
+func (usageRecorder) Response(context.Context, models.AiOrchestratorCallbackRequest) error {
+	return nil
+}
+
+type cancellationGuard struct{}
+
+func (cancellationGuard) IsCancelled(context.Context, string) (bool, error) { return false, nil }
+
+func TestSweepPromptLateAnswer(t *testing.T) {
+	t.Setenv("MAX_AI_RECEIVE_COUNT", "3")
+	ctx := context.Background()
+
+	repository := &promptRepository{documents: map[string]bson.M{}}
+	client := &llmClient{}
+	promptDispatcher := dispatcher.NewPromptDispatcher(client, repository, usageRecorder{}, pipeline.Default)
+	s := sweeper{
+		promptRepository:  repository,
+		promptDispatcher:  promptDispatcher,
+		cancellationGuard: cancellationGuard{},
+		pipeline:          pipeline.Default,
+	}
+
+	requestId, researchId, action, question := "request", "research", enumactions.Summarize, "question"
+	err := promptDispatcher.Dispatch(ctx, models.Prompt{
+		RequestId:  &requestId,
+		ResearchId: &researchId,
+		Action:     &action,
+		Question:   &question,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var timedOut models.Prompt
+	id := dispatcher.PromptId(requestId, &researchId, action, nil, nil)
+	if err = repository.GetById(ctx, id, &timedOut); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = s.sweepPrompt(ctx, timedOut, time.Now().UTC()); err != nil {
+		t.Fatal(err)
+	}
+	if len(client.sent) != 2 {
+		t.Fatalf("sweepPrompt() sent %d prompts, want the first and the re-dispatched one", len(client.sent))
+	}
+
+	first, again := client.sent[0], client.sent[1]
+	if *first.DispatchId == *again.DispatchId {
+		t.Fatalf("re-dispatch kept the dispatch id %s", *first.DispatchId)
+	}
+	if again.ReceiveCount != 1 {
+		t.Errorf("re-dispatch receive count = %d, want 1", again.ReceiveCount)
+	}
+
+	// A second sweep of the prompt as it was found, as a concurrent sweeper
+	// would, must not dispatch it once more.
+	if err = s.sweepPrompt(ctx, timedOut, time.Now().UTC()); err != nil {
+		t.Fatal(err)
+	}
+	if len(client.sent) != 2 {
+		t.Errorf("stale sweep sent %d prompts, want 2", len(client.sent))
+	}
+
+	callback := func(prompt models.Prompt) models.AiOrchestratorCallbackRequest {
+		return models.AiOrchestratorCallbackRequest{
+			RequestId:  prompt.RequestId,
+			ResearchId: prompt.ResearchId,
+			Action:     prompt.Action,
+			DispatchId: prompt.DispatchId,
+		}
+	}
+
+	tests := []struct {
+		name   string
+		prompt models.Prompt
+		want   bool
+	}{
+		{"late answer of the first dispatch", first, false},
+		{"answer of the re-dispatch", again, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			awaited, err := promptDispatcher.Awaits(ctx, callback(tt.prompt))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if awaited != tt.want {
+				t.Errorf("Awaits() = %v, want %v", awaited, tt.want)
+			}
+		})
+	}
+
+	if err = promptDispatcher.Resolve(ctx, requestId, &researchId, action, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	awaited, err := promptDispatcher.Awaits(ctx, callback(again))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if awaited {
+		t.Error("Awaits() = true for the answer of a resolved prompt")
+	}
+}
@@ -0,0 +1,287 @@
+package tracker
+
+import (
+	"context"
+	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/delivery/dtos"
+	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/builder"
+	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/interfaces"
+	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/models"
+	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/pipeline"
+	enumstatus "github.com/PesquisAi/pesquisai-database-lib/sql/enums/status"
+	"go.mongodb.org/mongo-driver/bson"
+	"log/slog"
+	"time"
+)
+
+const batchSize = 100
+
+// researchTracker keeps, on the orchestrator document of a request, the set of
+// researches that already ended, and emits the request completion once the
+// fan-out started by google-search has fully come back. The total of the
+// fan-out is recorded on the document as soon as it is known; the requests
+// whose researches all ended before are settled by the sweeper. Researches handed to
+// the web-scraper are given a deadline, so the ones it never sends back still
+// end.
+type researchTracker struct {
+	queueOrchestrator      interfaces.Queue
+	queueStatusManager     interfaces.Queue
+	orchestratorRepository interfaces.OrchestratorRepository
+	requestRepository      interfaces.RequestRepository
+	pipeline               pipeline.Pipeline
+	webScraperTimeout      time.Duration
+}
+
+// total is the number of researches google-search published for the request,
+// 0 while it is not known. The researches created so far are not counted, as
+// they may still be a part of the fan-out.
+func (t researchTracker) total(ctx context.Context, requestId string) (int, error) {
+	request, err := t.requestRepository.GetWithRelations(ctx, requestId)
+	if err != nil {
+		return 0, err
+	}
+
+	if request.TotalResearches == nil {
+		return 0, nil
+	}
+	return *request.TotalResearches, nil
+}
+
+// record keeps the total of researches on the orchestrator document once
+// google-search published it, telling whether it is known.
+func (t researchTracker) record(ctx context.Context, requestId string) (bool, error) {
+	total, err := t.total(ctx, requestId)
+	if err != nil || total == 0 {
+		return false, err
+	}
+
+	_, err = t.orchestratorRepository.UpdateOne(ctx,
+		bson.M{"_id": requestId},
+		bson.M{"$set": bson.M{"totalResearches": total}},
+	)
+	return err == nil, err
+}
+
+// settle emits the completion of the request once every research of the
+// fan-out ended. The finished researches are compared to the total by the
+// update itself, so of the researches ending at once only one completes it.
+func (t researchTracker) settle(ctx context.Context, requestId string, now time.Time) (bool, error) {
+	completed, err := t.orchestratorRepository.UpdateOne(ctx,
+		bson.M{
+			"_id":             requestId,
+			"completedAt":     bson.M{"$exists": false},
+			"totalResearches": bson.M{"$gt": 0},
+			"$expr": bson.M{"$gte": bson.A{
+				bson.M{"$size": bson.M{"$ifNull": bson.A{"$finishedResearches", bson.A{}}}},
+				"$totalResearches",
+			}},
+		},
+		bson.M{"$set": bson.M{"completedAt": now}},
+	)
+	if err != nil || !completed {
+		return false, err
+	}
+	return true, t.complete(ctx, requestId)
+}
+
+func (t researchTracker) complete(ctx context.Context, requestId string) error {
+	if t.pipeline.Completion == "" {
+		return nil
+	}
+
+	action := t.pipeline.Completion
+	b, err := builder.BuildQueueOrchestratorMessage(dtos.AiOrchestratorRequest{
+		RequestId: &requestId,
+		Action:    &action,
+	})
+	if err != nil {
+		return err
+	}
+
+	return t.queueOrchestrator.Publish(ctx, b)
+}
+
+func (t researchTracker) Finish(ctx context.Context, requestId, researchId string) error {
+	slog.InfoContext(ctx, "researchTracker.Finish",
+		slog.String("details", "process started"),
+		slog.String("requestId", requestId),
+		slog.String("researchId", researchId))
+
+	now := time.Now().UTC()
+	var request models.OrchestratorRequest
+	err := t.orchestratorRepository.FindOneAndUpdate(ctx, requestId, bson.M{
+		"$addToSet": bson.M{"finishedResearches": researchId},
+		"$set":      bson.M{"updatedAt": now},
+	}, &request)
+	if err != nil {
+		slog.ErrorContext(ctx, "researchTracker.Finish",
+			slog.String("details", "process error"),
+			slog.String("error", err.Error()))
+		return err
+	}
+
+	if request.TotalResearches == nil {
+		var known bool
+		known, err = t.record(ctx, requestId)
+		if err != nil {
+			slog.ErrorContext(ctx, "researchTracker.Finish",
+				slog.String("details", "process error"),
+				slog.String("error", err.Error()))
+			return err
+		}
+
+		if !known {
+			slog.WarnContext(ctx, "researchTracker.Finish",
+				slog.String("details", "total of researches not known yet, completion left to the sweeper"),
+				slog.Int("finished", len(request.FinishedResearches)))
+			return nil
+		}
+	}
+
+	completed, err := t.settle(ctx, requestId, now)
+	if err != nil {
+		slog.ErrorContext(ctx, "researchTracker.Finish",
+			slog.String("details", "process error"),
+			slog.String("error", err.Error()))
+		return err
+	}
+
+	if completed {
+		slog.InfoContext(ctx, "researchTracker.Finish",
+			slog.String("details", "request completed"))
+		return nil
+	}
+
+	slog.InfoContext(ctx, "researchTracker.Finish",
+		slog.String("details", "process finished"),
+		slog.Int("finished", len(request.FinishedResearches)))
+	return nil
+}
+
+func (t researchTracker) Settle(ctx context.Context) error {
+	now := time.Now().UTC()
+
+	var requests []models.OrchestratorRequest
+	err := t.orchestratorRepository.Find(ctx, bson.M{
+		"finishedResearches.0": bson.M{"$exists": true},
+		"totalResearches":      bson.M{"$exists": false},
+		"completedAt":          bson.M{"$exists": false},
+		"cancelledAt":          bson.M{"$exists": false},
+	}, batchSize, &requests)
+	if err != nil {
+		return err
+	}
+
+	for _, request := range requests {
+		known, err := t.record(ctx, *request.ID)
+		if err == nil && known {
+			_, err = t.settle(ctx, *request.ID, now)
+		}
+		if err != nil {
+			slog.ErrorContext(ctx, "researchTracker.Settle",
+				slog.String("details", "process error"),
+				slog.String("requestId", *request.ID),
+				slog.String("error", err.Error()))
+		}
+	}
+	return nil
+}
+
+func (t researchTracker) Fail(ctx context.Context, requestId, researchId string) error {
+	_, err := t.orchestratorRepository.UpdateOne(ctx,
+		bson.M{"_id": requestId},
+		bson.M{"$pull": bson.M{"summarizedResearches": researchId}},
+	)
+	if err != nil {
+		return err
+	}
+
+	b, err := builder.BuildQueueStatusManagerMessage(nil, &researchId, enumstatus.ERROR)
+	if err != nil {
+		return err
+	}
+
+	err = t.queueStatusManager.Publish(ctx, b)
+	if err != nil {
+		return err
+	}
+	return t.Finish(ctx, requestId, researchId)
+}
+
+func (t researchTracker) HandOff(ctx context.Context, researchId string) error {
+	deadline := time.Now().UTC().Add(t.webScraperTimeout)
+	_, err := t.orchestratorRepository.UpdateOne(ctx,
+		bson.M{"_id": researchId, "handoff.expiredAt": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"handoff.deadline": deadline}},
+	)
+	return err
+}
+
+func (t researchTracker) Resume(ctx context.Context, research models.OrchestratorResearch) (bool, error) {
+	if research.Handoff == nil {
+		return true, nil
+	}
+	if research.Handoff.ExpiredAt != nil {
+		return false, nil
+	}
+	if research.Handoff.Deadline == nil {
+		return true, nil
+	}
+
+	// Conditioned on the deadline, so either the content or the expiration
+	// wins when both happen at once.
+	return t.orchestratorRepository.UpdateOne(ctx,
+		bson.M{"_id": *research.ID, "handoff.deadline": *research.Handoff.Deadline, "handoff.expiredAt": bson.M{"$exists": false}},
+		bson.M{"$unset": bson.M{"handoff.deadline": ""}},
+	)
+}
+
+func (t researchTracker) expire(ctx context.Context, research models.OrchestratorResearch, now time.Time) error {
+	expired, err := t.orchestratorRepository.UpdateOne(ctx,
+		bson.M{"_id": *research.ID, "handoff.deadline": *research.Handoff.Deadline, "handoff.expiredAt": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"handoff.expiredAt": now}},
+	)
+	if err != nil || !expired {
+		return err
+	}
+
+	slog.WarnContext(ctx, "researchTracker.expire",
+		slog.String("details", "web-scraper did not send the content back in time, failing research"),
+		slog.String("researchId", *research.ID))
+
+	return t.Fail(ctx, *research.RequestID, *research.ID)
+}
+
+func (t researchTracker) Expire(ctx context.Context) error {
+	now := time.Now().UTC()
+
+	var researches []models.OrchestratorResearch
+	err := t.orchestratorRepository.Find(ctx, bson.M{
+		"handoff.deadline":  bson.M{"$lt": now},
+		"handoff.expiredAt": bson.M{"$exists": false},
+	}, batchSize, &researches)
+	if err != nil {
+		return err
+	}
+
+	for _, research := range researches {
+		err = t.expire(ctx, research, now)
+		if err != nil {
+			slog.ErrorContext(ctx, "researchTracker.Expire",
+				slog.String("details", "process error"),
+				slog.String("researchId", *research.ID),
+				slog.String("error", err.Error()))
+		}
+	}
+	return nil
+}
+
+func NewResearchTracker(queueOrchestrator, queueStatusManager interfaces.Queue, orchestratorRepository interfaces.OrchestratorRepository, requestRepository interfaces.RequestRepository, pipeline pipeline.Pipeline, webScraperTimeout time.Duration) interfaces.ResearchTracker {
+	return &researchTracker{
+		queueOrchestrator:      queueOrchestrator,
+		queueStatusManager:     queueStatusManager,
+		orchestratorRepository: orchestratorRepository,
+		requestRepository:      requestRepository,
+		pipeline:               pipeline,
+		webScraperTimeout:      webScraperTimeout,
+	}
+}
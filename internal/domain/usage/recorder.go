@@ -0,0 +1,103 @@
+package usage
+
+import (
+	"context"
+	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/interfaces"
+	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	kindPrompt   = "prompt"
+	kindResponse = "response"
+)
+
+// recorder keeps a usage record for every prompt and answer and adds it to
+// the usage totals of the request, overall and by action.
+type recorder struct {
+	usageRepository        interfaces.UsageRepository
+	orchestratorRepository interfaces.OrchestratorRepository
+}
+
+func (r recorder) record(ctx context.Context, record models.UsageRecord, totals bson.M) error {
+	id := primitive.NewObjectID().Hex()
+	now := time.Now().UTC()
+	record.ID = &id
+	record.CreatedAt = &now
+
+	err := r.usageRepository.Create(ctx, &record)
+	if err != nil {
+		return err
+	}
+
+	inc := bson.M{}
+	for field, value := range totals {
+		inc["usage."+field] = value
+		inc["usage.actions."+*record.Action+"."+field] = value
+	}
+
+	_, err = r.orchestratorRepository.UpdateOne(ctx,
+		bson.M{"_id": *record.RequestId},
+		bson.M{"$inc": inc},
+	)
+	return err
+}
+
+func (r recorder) Prompt(ctx context.Context, prompt models.Prompt) error {
+	kind := kindPrompt
+	size := utf8.RuneCountInString(*prompt.Question)
+
+	totals := bson.M{"prompts": 1, "promptChars": size}
+	if prompt.ReceiveCount > 0 {
+		totals["retries"] = 1
+	}
+
+	return r.record(ctx, models.UsageRecord{
+		RequestId:    prompt.RequestId,
+		ResearchId:   prompt.ResearchId,
+		Action:       prompt.Action,
+		Chunk:        prompt.Chunk,
+		Voter:        prompt.Voter,
+		Kind:         &kind,
+		ReceiveCount: prompt.ReceiveCount,
+		PromptSize:   size,
+	}, totals)
+}
+
+func (r recorder) Response(ctx context.Context, callback models.AiOrchestratorCallbackRequest) error {
+	kind := kindResponse
+	size := utf8.RuneCountInString(*callback.Response)
+
+	var latency int64
+	if callback.DispatchedAt != nil {
+		latency = time.Since(*callback.DispatchedAt).Milliseconds()
+	}
+
+	totals := bson.M{"responses": 1, "responseChars": size, "latencyMs": latency}
+	if callback.Cached {
+		totals["cachedResponses"] = 1
+	}
+
+	return r.record(ctx, models.UsageRecord{
+		RequestId:    callback.RequestId,
+		ResearchId:   callback.ResearchId,
+		Action:       callback.Action,
+		Chunk:        callback.Chunk,
+		Voter:        callback.Voter,
+		Kind:         &kind,
+		ReceiveCount: callback.ReceiveCount,
+		ResponseSize: size,
+		LatencyMs:    latency,
+		Cached:       callback.Cached,
+	}, totals)
+}
+
+func NewRecorder(usageRepository interfaces.UsageRepository, orchestratorRepository interfaces.OrchestratorRepository) interfaces.UsageRecorder {
+	return &recorder{
+		usageRepository:        usageRepository,
+		orchestratorRepository: orchestratorRepository,
+	}
+}
@@ -2,16 +2,71 @@ package usecases
 
 import (
 	"context"
+	"errors"
+	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/config/errortypes"
+	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/delivery/dtos"
+	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/builder"
+	enumactions "github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/enums/actions"
 	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/factory"
 	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/interfaces"
 	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/models"
+	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/pipeline"
+	"github.com/PesquisAi/pesquisai-errors-lib/exceptions"
+	"go.mongodb.org/mongo-driver/bson"
 	"log/slog"
+	"time"
 )
 
+const correctionTemplate = "correction"
+
+// correction is the data of the prompt sent after an invalid answer, feeding
+// the answer and what is wrong with it back to the LLM.
+type correction struct {
+	Question string
+	Answer   string
+	Problems []string
+}
+
 type UseCase struct {
 	requestRepository      interfaces.RequestRepository
 	serviceFactory         *factory.ServiceFactory
 	orchestratorRepository interfaces.OrchestratorRepository
+	queueOrchestrator      interfaces.Queue
+	promptDispatcher       interfaces.PromptDispatcher
+	cancellationGuard      interfaces.CancellationGuard
+	deduplicationStore     interfaces.DeduplicationStore
+	transactor             interfaces.Transactor
+	promptRegistry         interfaces.PromptRegistry
+	responseCache          interfaces.ResponseCache
+	usageRecorder          interfaces.UsageRecorder
+	pipeline               pipeline.Pipeline
+}
+
+func (u UseCase) route(ctx context.Context, request models.AiOrchestratorCallbackRequest, outcome string) error {
+	next, ok := u.pipeline.Next(*request.Action, outcome)
+	if !ok {
+		slog.DebugContext(ctx, "useCase.route",
+			slog.String("details", "no next stage"),
+			slog.String("action", *request.Action),
+			slog.String("outcome", outcome))
+		return nil
+	}
+
+	b, err := builder.BuildQueueOrchestratorMessage(dtos.AiOrchestratorRequest{
+		RequestId:  request.RequestId,
+		ResearchId: request.ResearchId,
+		Action:     &next,
+	})
+	if err != nil {
+		return err
+	}
+
+	slog.InfoContext(ctx, "useCase.route",
+		slog.String("details", "routing to next stage"),
+		slog.String("action", *request.Action),
+		slog.String("outcome", outcome),
+		slog.String("next", next))
+	return u.queueOrchestrator.Publish(ctx, b)
 }
 
 func (u UseCase) OrchestrateCallback(ctx context.Context, request models.AiOrchestratorCallbackRequest) error {
@@ -19,7 +74,21 @@ func (u UseCase) OrchestrateCallback(ctx context.Context, request models.AiOrche
 		slog.String("details", "process started"),
 		slog.String("action", *request.Action))
 
-	service, err := u.serviceFactory.Factory(*request.Action)
+	cancelled, err := u.cancellationGuard.IsCancelled(ctx, *request.RequestId)
+	if err != nil {
+		slog.ErrorContext(ctx, "useCase.OrchestrateCallback",
+			slog.String("details", "process error"),
+			slog.String("error", err.Error()))
+		return err
+	}
+
+	if cancelled {
+		slog.InfoContext(ctx, "useCase.OrchestrateCallback",
+			slog.String("details", "request cancelled, dropping callback"))
+		return u.promptDispatcher.Resolve(ctx, *request.RequestId, request.ResearchId, *request.Action, request.Chunk, request.Voter)
+	}
+
+	claimed, err := u.deduplicationStore.Claim(ctx, request)
 	if err != nil {
 		slog.ErrorContext(ctx, "useCase.OrchestrateCallback",
 			slog.String("details", "process error"),
@@ -27,25 +96,189 @@ func (u UseCase) OrchestrateCallback(ctx context.Context, request models.AiOrche
 		return err
 	}
 
-	err = service.Callback(ctx, request)
+	if !claimed {
+		slog.InfoContext(ctx, "useCase.OrchestrateCallback",
+			slog.String("details", "callback already processed, acknowledging redelivery"),
+			slog.Int("receiveCount", request.ReceiveCount))
+		return nil
+	}
+
+	// The state changes of the callback and the messages it publishes through
+	// the outbox are committed together.
+	err = u.transactor.WithTransaction(ctx, func(ctx context.Context) error {
+		return u.processCallback(ctx, request)
+	})
 	if err != nil {
+		// An invalid answer is a processed attempt: its retry is a new attempt.
+		// Any other failure releases the attempt so the redelivery can run it.
+		var exception *exceptions.Error
+		if errors.As(err, &exception) && exception.Code == errortypes.InvalidAiResponseCode {
+			u.record(ctx, request)
+			u.evict(ctx, request)
+			u.correct(ctx, request, exception)
+			err = errors.Join(err, u.deduplicationStore.Complete(ctx, request))
+		} else {
+			err = errors.Join(err, u.deduplicationStore.Release(ctx, request))
+		}
 		slog.ErrorContext(ctx, "useCase.OrchestrateCallback",
 			slog.String("details", "process error"),
 			slog.String("error", err.Error()))
 		return err
 	}
 
+	u.record(ctx, request)
+
+	err = u.deduplicationStore.Complete(ctx, request)
+	if err != nil {
+		slog.WarnContext(ctx, "useCase.OrchestrateCallback",
+			slog.String("details", "could not mark callback as processed"),
+			slog.String("error", err.Error()))
+	}
+
 	slog.DebugContext(ctx, "useCase.OrchestrateCallback",
 		slog.String("details", "process finished"))
 	return nil
 }
 
+// correct records the rejected answer on the request and turns the question
+// of the next attempt into a corrective one. When it fails, the next attempt
+// is sent with the original question.
+func (u UseCase) correct(ctx context.Context, request models.AiOrchestratorCallbackRequest, exception *exceptions.Error) {
+	now := time.Now().UTC()
+	_, err := u.orchestratorRepository.UpdateOne(ctx,
+		bson.M{"_id": *request.RequestId},
+		bson.M{
+			"$push": bson.M{"attempts": models.Attempt{
+				Action:       request.Action,
+				ResearchId:   request.ResearchId,
+				Chunk:        request.Chunk,
+				Voter:        request.Voter,
+				ReceiveCount: request.ReceiveCount,
+				Answer:       request.Response,
+				Messages:     exception.Messages,
+				CreatedAt:    &now,
+			}},
+			"$set": bson.M{"updatedAt": now},
+		},
+	)
+	if err != nil {
+		slog.WarnContext(ctx, "useCase.correct",
+			slog.String("details", "could not record attempt"),
+			slog.String("error", err.Error()))
+	}
+
+	question, ok := exception.Forward["question"].(string)
+	if !ok {
+		return
+	}
+
+	corrected, _, err := u.promptRegistry.Render(correctionTemplate, "", correction{
+		Question: question,
+		Answer:   *request.Response,
+		Problems: exception.Messages,
+	})
+	if err != nil {
+		slog.WarnContext(ctx, "useCase.correct",
+			slog.String("details", "could not build corrective question"),
+			slog.String("error", err.Error()))
+		return
+	}
+	exception.Forward["question"] = corrected
+}
+
+// record accounts the answer in the usage of the request. Only processed
+// answers are recorded, so a released attempt is not counted twice, and an
+// abstention has no answer to record.
+func (u UseCase) record(ctx context.Context, request models.AiOrchestratorCallbackRequest) {
+	if request.Abstained {
+		return
+	}
+
+	err := u.usageRecorder.Response(ctx, request)
+	if err != nil {
+		slog.WarnContext(ctx, "useCase.record",
+			slog.String("details", "could not record usage"),
+			slog.String("error", err.Error()))
+	}
+}
+
+// evict drops a rejected answer from the response cache, so the retry is not
+// answered with it again.
+func (u UseCase) evict(ctx context.Context, request models.AiOrchestratorCallbackRequest) {
+	err := u.responseCache.Evict(ctx, request)
+	if err != nil {
+		slog.WarnContext(ctx, "useCase.evict",
+			slog.String("details", "could not evict cached response"),
+			slog.String("error", err.Error()))
+	}
+}
+
+func (u UseCase) processCallback(ctx context.Context, request models.AiOrchestratorCallbackRequest) error {
+	// Checked within the transaction, so a late answer is dropped even when
+	// the sweeper dispatches the prompt again while it is processed.
+	awaited, err := u.promptDispatcher.Awaits(ctx, request)
+	if err != nil {
+		return err
+	}
+
+	if !awaited {
+		slog.InfoContext(ctx, "useCase.processCallback",
+			slog.String("details", "prompt resolved or dispatched again, dropping late answer"),
+			slog.Int("receiveCount", request.ReceiveCount))
+		return nil
+	}
+
+	service, err := u.serviceFactory.Factory(*request.Action)
+	if err != nil {
+		return err
+	}
+
+	outcome, err := service.Callback(ctx, request)
+	if err != nil {
+		return err
+	}
+
+	if !request.Abstained {
+		err = u.responseCache.Store(ctx, request)
+		if err != nil {
+			slog.WarnContext(ctx, "useCase.processCallback",
+				slog.String("details", "could not cache response"),
+				slog.String("error", err.Error()))
+		}
+	}
+
+	err = u.promptDispatcher.Resolve(ctx, *request.RequestId, request.ResearchId, *request.Action, request.Chunk, request.Voter)
+	if err != nil {
+		slog.WarnContext(ctx, "useCase.processCallback",
+			slog.String("details", "could not resolve outstanding prompt"),
+			slog.String("error", err.Error()))
+	}
+
+	return u.route(ctx, request, outcome)
+}
+
 func (u UseCase) Orchestrate(ctx context.Context, request models.AiOrchestratorRequest) error {
 	slog.InfoContext(ctx, "useCase.Orchestrate",
 		slog.String("details", "process started"),
 		slog.String("action", *request.Action),
 	)
 
+	if *request.Action != enumactions.Cancel {
+		cancelled, err := u.cancellationGuard.IsCancelled(ctx, *request.RequestId)
+		if err != nil {
+			slog.ErrorContext(ctx, "useCase.Orchestrate",
+				slog.String("details", "process error"),
+				slog.String("error", err.Error()))
+			return err
+		}
+
+		if cancelled {
+			slog.InfoContext(ctx, "useCase.Orchestrate",
+				slog.String("details", "request cancelled, dropping action"))
+			return nil
+		}
+	}
+
 	service, err := u.serviceFactory.Factory(*request.Action)
 	if err != nil {
 		slog.ErrorContext(ctx, "useCase.Orchestrate",
@@ -54,7 +287,9 @@ func (u UseCase) Orchestrate(ctx context.Context, request models.AiOrchestratorR
 		return err
 	}
 
-	err = service.Execute(ctx, request)
+	err = u.transactor.WithTransaction(ctx, func(ctx context.Context) error {
+		return service.Execute(ctx, request)
+	})
 	if err != nil {
 		slog.ErrorContext(ctx, "useCase.Orchestrate",
 			slog.String("details", "process error"),
@@ -67,9 +302,19 @@ func (u UseCase) Orchestrate(ctx context.Context, request models.AiOrchestratorR
 	return nil
 }
 
-func NewUseCase(requestRepository interfaces.RequestRepository, serviceFactory *factory.ServiceFactory) interfaces.UseCase {
+func NewUseCase(requestRepository interfaces.RequestRepository, orchestratorRepository interfaces.OrchestratorRepository, serviceFactory *factory.ServiceFactory, queueOrchestrator interfaces.Queue, promptDispatcher interfaces.PromptDispatcher, cancellationGuard interfaces.CancellationGuard, deduplicationStore interfaces.DeduplicationStore, transactor interfaces.Transactor, promptRegistry interfaces.PromptRegistry, responseCache interfaces.ResponseCache, usageRecorder interfaces.UsageRecorder, pipeline pipeline.Pipeline) interfaces.UseCase {
 	return &UseCase{
-		requestRepository: requestRepository,
-		serviceFactory:    serviceFactory,
+		requestRepository:      requestRepository,
+		orchestratorRepository: orchestratorRepository,
+		serviceFactory:         serviceFactory,
+		queueOrchestrator:      queueOrchestrator,
+		promptDispatcher:       promptDispatcher,
+		cancellationGuard:      cancellationGuard,
+		deduplicationStore:     deduplicationStore,
+		transactor:             transactor,
+		promptRegistry:         promptRegistry,
+		responseCache:          responseCache,
+		usageRecorder:          usageRecorder,
+		pipeline:               pipeline,
 	}
 }
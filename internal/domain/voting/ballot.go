@@ -0,0 +1,130 @@
+package voting
+
+import (
+	"context"
+	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/interfaces"
+	"github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"strconv"
+	"time"
+)
+
+// ballot collects the votes of an ensemble on the decision of one action for a
+// research. Votes, abstentions and the decision are kept on the research
+// document; only the vote or abstention that decides reports it, so the
+// decision is acted upon once.
+type ballot struct {
+	orchestratorRepository interfaces.OrchestratorRepository
+	action                 string
+	ensemble               Ensemble
+}
+
+func (b ballot) Voters() []string {
+	return b.ensemble.Voters
+}
+
+// Open discards the votes of a previous run of the action, e.g. a replay.
+func (b ballot) Open(ctx context.Context, researchId string) error {
+	_, err := b.orchestratorRepository.UpdateOne(ctx,
+		bson.M{"_id": researchId},
+		bson.M{"$unset": bson.M{
+			"votes." + b.action:       "",
+			"abstentions." + b.action: "",
+			"decisions." + b.action:   "",
+		}},
+	)
+	return err
+}
+
+func (b ballot) Cast(ctx context.Context, researchId string, voter int, vote models.Vote) (bool, models.Decision, error) {
+	if voter < len(b.ensemble.Voters) {
+		vote.Provider = &b.ensemble.Voters[voter]
+	}
+
+	now := time.Now().UTC()
+	vote.CreatedAt = &now
+	var research models.OrchestratorResearch
+	err := b.orchestratorRepository.FindOneAndUpdate(ctx, researchId, bson.M{
+		"$set": bson.M{
+			"votes." + b.action + "." + strconv.Itoa(voter): vote,
+			"updatedAt": now,
+		},
+	}, &research)
+	if err != nil {
+		return false, models.Decision{}, err
+	}
+
+	return b.decide(ctx, researchId, research, now)
+}
+
+// Abstain leaves the voter out of the ensemble, the others deciding alone. It
+// is a no-op once the ballot is decided. The decision of a ballot every voter
+// abstained from has neither yes nor no votes.
+func (b ballot) Abstain(ctx context.Context, researchId string, voter int) (bool, models.Decision, error) {
+	now := time.Now().UTC()
+	recorded, err := b.orchestratorRepository.UpdateOne(ctx,
+		bson.M{"_id": researchId, "decisions." + b.action: bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{
+			"abstentions." + b.action + "." + strconv.Itoa(voter): now,
+			"updatedAt": now,
+		}},
+	)
+	if err != nil || !recorded {
+		return false, models.Decision{}, err
+	}
+
+	var research models.OrchestratorResearch
+	err = b.orchestratorRepository.GetById(ctx, researchId, &research)
+	if err != nil {
+		return false, models.Decision{}, err
+	}
+
+	return b.decide(ctx, researchId, research, now)
+}
+
+func (b ballot) decide(ctx context.Context, researchId string, research models.OrchestratorResearch, now time.Time) (bool, models.Decision, error) {
+	var yes, no, score int
+	for _, vote := range research.Votes[b.action] {
+		score += vote.Score
+		if vote.Worth {
+			yes++
+		} else {
+			no++
+		}
+	}
+	abstained := len(research.Abstentions[b.action])
+
+	result, decided := b.ensemble.Decide(yes, no, abstained)
+	if !decided {
+		return false, models.Decision{}, nil
+	}
+
+	decision := models.Decision{
+		Worth:     result,
+		Rule:      b.ensemble.Rule,
+		Yes:       yes,
+		No:        no,
+		Abstained: abstained,
+		DecidedAt: &now,
+	}
+	if yes+no > 0 {
+		decision.Score = score / (yes + no)
+	}
+
+	won, err := b.orchestratorRepository.UpdateOne(ctx,
+		bson.M{"_id": researchId, "decisions." + b.action: bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"decisions." + b.action: decision}},
+	)
+	if err != nil || !won {
+		return false, models.Decision{}, err
+	}
+	return true, decision, nil
+}
+
+func NewBallot(orchestratorRepository interfaces.OrchestratorRepository, action string, ensemble Ensemble) interfaces.Ballot {
+	return &ballot{
+		orchestratorRepository: orchestratorRepository,
+		action:                 action,
+		ensemble:               ensemble,
+	}
+}
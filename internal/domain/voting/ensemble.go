@@ -0,0 +1,52 @@
+package voting
+
+import (
+	"fmt"
+	enumvoting "github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/enums/voting"
+)
+
+// Ensemble is the set of voters deciding a yes/no question and the rule their
+// votes are aggregated with:
+//   - majority accepts when more than half of the voters say yes;
+//   - any-yes accepts when a single voter says yes;
+//   - unanimous accepts when every voter says yes.
+//
+// Voters are providers, the same provider listed more than once being sampled
+// once per listing.
+type Ensemble struct {
+	Voters []string
+	Rule   string
+}
+
+// Decide tells the decision of the given votes, as soon as the votes still
+// missing can no longer change it. Voters who abstained are left out of the
+// ensemble, the others deciding alone.
+func (e Ensemble) Decide(yes, no, abstained int) (worth, decided bool) {
+	n := len(e.Voters) - abstained
+	switch e.Rule {
+	case enumvoting.AnyYes:
+		if yes > 0 {
+			return true, true
+		}
+		return false, no >= n
+	case enumvoting.Unanimous:
+		if no > 0 {
+			return false, true
+		}
+		return true, yes >= n
+	default:
+		if yes > n/2 {
+			return true, true
+		}
+		return false, no >= n-n/2
+	}
+}
+
+func NewEnsemble(voters []string, rule string) (Ensemble, error) {
+	switch rule {
+	case enumvoting.Majority, enumvoting.AnyYes, enumvoting.Unanimous:
+	default:
+		return Ensemble{}, fmt.Errorf("unknown voting rule '%s'", rule)
+	}
+	return Ensemble{Voters: voters, Rule: rule}, nil
+}
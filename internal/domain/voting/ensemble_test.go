@@ -0,0 +1,61 @@
+package voting
+
+import (
+	enumvoting "github.com/PesquisAi/pesquisai-ai-orchestrator/internal/domain/enums/voting"
+	"testing"
+)
+
+func TestDecide(t *testing.T) {
+	three := []string{"gemini", "openai", "gemini"}
+	four := []string{"gemini", "openai", "gemini", "openai"}
+
+	tests := []struct {
+		name        string
+		voters      []string
+		rule        string
+		yes, no     int
+		abstained   int
+		wantWorth   bool
+		wantDecided bool
+	}{
+		{"majority no votes yet", three, enumvoting.Majority, 0, 0, 0, false, false},
+		{"majority reached", three, enumvoting.Majority, 2, 0, 0, true, true},
+		{"majority one yes", three, enumvoting.Majority, 1, 1, 0, false, false},
+		{"majority rejected", three, enumvoting.Majority, 0, 2, 0, false, true},
+		{"majority even tie rejects", four, enumvoting.Majority, 2, 2, 0, false, true},
+		{"majority even half yes", four, enumvoting.Majority, 2, 1, 0, false, false},
+		{"majority even reached", four, enumvoting.Majority, 3, 0, 0, true, true},
+		{"any-yes one yes", three, enumvoting.AnyYes, 1, 0, 0, true, true},
+		{"any-yes waiting", three, enumvoting.AnyYes, 0, 2, 0, false, false},
+		{"any-yes every no", three, enumvoting.AnyYes, 0, 3, 0, false, true},
+		{"unanimous one no", three, enumvoting.Unanimous, 0, 1, 0, false, true},
+		{"unanimous waiting", three, enumvoting.Unanimous, 2, 0, 0, true, false},
+		{"unanimous every yes", three, enumvoting.Unanimous, 3, 0, 0, true, true},
+		{"single voter yes", []string{"gemini"}, enumvoting.Majority, 1, 0, 0, true, true},
+		{"single voter no", []string{"gemini"}, enumvoting.Majority, 0, 1, 0, false, true},
+		{"majority one abstained", three, enumvoting.Majority, 1, 0, 1, false, false},
+		{"majority reached without the abstained", three, enumvoting.Majority, 1, 0, 2, true, true},
+		{"majority tie without the abstained", four, enumvoting.Majority, 1, 1, 2, false, true},
+		{"any-yes every other no", three, enumvoting.AnyYes, 0, 2, 1, false, true},
+		{"unanimous every other yes", three, enumvoting.Unanimous, 2, 0, 1, true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ensemble, err := NewEnsemble(tt.voters, tt.rule)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			worth, decided := ensemble.Decide(tt.yes, tt.no, tt.abstained)
+			if decided != tt.wantDecided || (decided && worth != tt.wantWorth) {
+				t.Errorf("Decide(%d, %d, %d) = %v, %v, want %v, %v", tt.yes, tt.no, tt.abstained, worth, decided, tt.wantWorth, tt.wantDecided)
+			}
+		})
+	}
+}
+
+func TestNewEnsemble(t *testing.T) {
+	if _, err := NewEnsemble([]string{"gemini"}, "most"); err == nil {
+		t.Error("NewEnsemble() accepted the unknown rule 'most'")
+	}
+}
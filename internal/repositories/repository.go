@@ -0,0 +1,91 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	nosqlrepositories "github.com/PesquisAi/pesquisai-database-lib/nosql/repositories"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"time"
+)
+
+// Repository extends the shared nosql repository with the atomic operations
+// the orchestrator needs on its own documents.
+type Repository struct {
+	*nosqlrepositories.Repository
+	collection *mongo.Collection
+}
+
+func (s *Repository) FindOneAndUpdate(ctx context.Context, id string, update bson.M, model interface{}) error {
+	filter := bson.M{"_id": id}
+	op := options.FindOneAndUpdate().
+		SetUpsert(false).
+		SetReturnDocument(options.After)
+
+	return s.collection.FindOneAndUpdate(ctx, filter, update, op).Decode(model)
+}
+
+// UpdateOneAndGet updates the document matching filter and decodes it, as it
+// is after the update, into model. It tells whether a document matched.
+func (s *Repository) UpdateOneAndGet(ctx context.Context, filter bson.M, update bson.M, model interface{}) (bool, error) {
+	op := options.FindOneAndUpdate().
+		SetUpsert(false).
+		SetReturnDocument(options.After)
+
+	err := s.collection.FindOneAndUpdate(ctx, filter, update, op).Decode(model)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (s *Repository) UpdateOne(ctx context.Context, filter bson.M, update bson.M) (bool, error) {
+	res, err := s.collection.UpdateOne(ctx, filter, update, options.Update().SetUpsert(false))
+	if err != nil {
+		return false, err
+	}
+
+	return res.ModifiedCount > 0, nil
+}
+
+func (s *Repository) Upsert(ctx context.Context, id string, values bson.M) error {
+	filter := bson.M{"_id": id}
+	update := bson.M{"$set": values}
+
+	_, err := s.collection.UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+	return err
+}
+
+func (s *Repository) Find(ctx context.Context, filter bson.M, limit int64, models interface{}) error {
+	cursor, err := s.collection.Find(ctx, filter, options.Find().SetLimit(limit))
+	if err != nil {
+		return err
+	}
+
+	return cursor.All(ctx, models)
+}
+
+func (s *Repository) Delete(ctx context.Context, id string) error {
+	_, err := s.collection.DeleteOne(ctx, bson.M{"_id": id})
+	return err
+}
+
+func (s *Repository) EnsureTTLIndex(ctx context.Context, field string, ttl time.Duration) error {
+	_, err := s.collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: field, Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(int32(ttl.Seconds())),
+	})
+	return err
+}
+
+func (s *Repository) Connect(database, collection string) {
+	s.Repository.Connect(database, collection)
+	if s.collection == nil {
+		s.collection = s.Connection.GetDatabaseCollection(database, collection)
+	}
+}
+
+func NewRepository(repository *nosqlrepositories.Repository) *Repository {
+	return &Repository{Repository: repository}
+}
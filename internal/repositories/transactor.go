@@ -0,0 +1,31 @@
+package repositories
+
+import (
+	"context"
+	"github.com/PesquisAi/pesquisai-database-lib/nosql/connection"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Transactor runs a function inside a mongo transaction. Every repository call
+// made with the context handed to the function takes part in the transaction.
+type Transactor struct {
+	Connection *connection.Connection
+	Disabled   bool
+}
+
+func (t *Transactor) WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if t.Disabled {
+		return fn(ctx)
+	}
+
+	session, err := t.Connection.StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(sessionCtx mongo.SessionContext) (interface{}, error) {
+		return nil, fn(sessionCtx)
+	})
+	return err
+}